@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const auditLogPath = "audit.jsonl"
+
+// types of audit events
+const (
+	eventBookingCreated   = "booking_created"
+	eventBookingCancelled = "booking_cancelled"
+	eventTicketSent       = "ticket_sent"
+	eventSendFailed       = "send_failed"
+	eventPromoteFailed    = "promote_failed" //a waitlist entry couldn't be booked, it stays on the waitlist
+)
+
+// auditEvent is one thing that happened, written to the audit log as one JSON object per line.
+// The ledger is what the app restores from, the audit log is only read by people and the report command.
+type auditEvent struct {
+	Type       string    `json:"type"`
+	At         time.Time `json:"at"`
+	Conference string    `json:"conference,omitempty"`
+	Reference  string    `json:"reference,omitempty"`
+	Email      string    `json:"email,omitempty"`
+	Tickets    uint      `json:"tickets,omitempty"` //booked, cancelled or sent
+	Tier       string    `json:"tier,omitempty"`
+	Total      int64     `json:"total,omitempty"`  //in cents
+	Refund     int64     `json:"refund,omitempty"` //in cents
+	Waitlist   string    `json:"waitlist,omitempty"`
+	Attempt    int       `json:"attempt,omitempty"`
+	GaveUp     bool      `json:"gaveUp,omitempty"` //the last attempt failed, the tickets were never sent
+	Error      string    `json:"error,omitempty"`
+}
+
+// auditLog appends events to a file, it is never rewritten
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// auditTrail is where every event goes, nothing is recorded while it is nil
+var auditTrail *auditLog
+
+func openAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: file}, nil
+}
+
+// record writes the event, a failure is only printed because the booking it is about has already happened
+func (a *auditLog) record(event auditEvent) {
+	if a == nil {
+		return
+	}
+	if event.At.IsZero() {
+		event.At = clock.Now().UTC()
+	}
+	line, err := json.Marshal(event)
+	if err == nil {
+		a.mu.Lock()
+		_, err = a.file.Write(append(line, '\n'))
+		a.mu.Unlock()
+	}
+	if err != nil {
+		fmt.Printf("Could not write to the audit log: %v\n", err)
+	}
+}
+
+func (a *auditLog) close() error {
+	if a == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+func bookingCreatedEvent(conferenceName string, booking UserData) auditEvent {
+	return auditEvent{
+		Type:       eventBookingCreated,
+		Conference: conferenceName,
+		Reference:  booking.reference,
+		Email:      booking.email,
+		Tickets:    booking.numberOfTickets,
+		Tier:       booking.tier,
+		Total:      booking.total,
+		Waitlist:   booking.waitlist,
+	}
+}
+
+func bookingCancelledEvent(conferenceName string, booking UserData, n uint, refund int64) auditEvent {
+	return auditEvent{
+		Type:       eventBookingCancelled,
+		Conference: conferenceName,
+		Reference:  booking.reference,
+		Email:      booking.email,
+		Tickets:    n,
+		Tier:       booking.tier,
+		Refund:     refund,
+	}
+}
+
+// promoteFailedEvent is a promote_failed event for a waitlist entry whose booking couldn't be written to the ledger
+func promoteFailedEvent(conferenceName string, entry waitlistEntry, err error) auditEvent {
+	return auditEvent{
+		Type:       eventPromoteFailed,
+		Conference: conferenceName,
+		Email:      entry.email,
+		Tickets:    entry.numberOfTickets,
+		Waitlist:   entry.reference,
+		Error:      err.Error(),
+	}
+}
+
+// sendEvent is a ticket_sent event, or a send_failed one if err isn't nil
+func sendEvent(job ticketJob, attempt int, err error) auditEvent {
+	event := auditEvent{
+		Type:       eventTicketSent,
+		Conference: job.conferenceName,
+		Reference:  job.reference,
+		Email:      job.email,
+		Tickets:    job.userTickets,
+		Attempt:    attempt,
+	}
+	if err != nil {
+		event.Type = eventSendFailed
+		event.Error = err.Error()
+		event.GaveUp = attempt >= maxSendAttempts
+	}
+	return event
+}
+
+// emailDomain is the part of an address after the @, lower case
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotEnoughTickets = errors.New("not enough tickets left")
+	ErrBookingNotFound  = errors.New("booking not found")
+	ErrAlreadyCancelled = errors.New("booking is already cancelled")
+)
+
+// Conference is one event with its own ticket pool and bookings
+type Conference struct {
+	mu               sync.Mutex //guards remainingTickets and bookings so concurrent bookings can't oversell
+	name             string
+	tickets          uint
+	startDate        time.Time
+	endDate          time.Time
+	remainingTickets uint
+	tiers            []TicketTier
+	bookings         []UserData
+	waitlist         []waitlistEntry
+	checkedIn        map[string]time.Time //ticket ID to when it was scanned at the door
+	seatMap          *SeatMap             //nil for general admission
+	seatsTaken       map[string]string    //seat label to the booking or hold reference sitting there
+	holds            []Hold               //tickets set aside for bookings that haven't been confirmed yet
+}
+
+// conferences is every event we sell tickets for, the first one is the default for old ledger entries.
+// It is built from the config at startup, defaultConfig has the built in ones.
+var conferences []*Conference
+
+// newConference makes a conference whose capacity is the sum of its tier quotas
+func newConference(name string, startDate time.Time, endDate time.Time, tiers ...TicketTier) *Conference {
+	var tickets uint
+	for _, tier := range tiers {
+		tickets += tier.quota
+	}
+	return &Conference{
+		name:             name,
+		tickets:          tickets,
+		startDate:        startDate,
+		endDate:          endDate,
+		remainingTickets: tickets,
+		tiers:            tiers,
+		bookings:         make([]UserData, 0),
+		checkedIn:        make(map[string]time.Time),
+		seatsTaken:       make(map[string]string),
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// isOpen is true until the conference starts, once it is sold out people can still join the waitlist
+func (c *Conference) isOpen(now time.Time) bool {
+	return now.Before(c.startDate)
+}
+
+// dates formats the conference dates for the menu
+func (c *Conference) dates() string {
+	if c.startDate.Equal(c.endDate) {
+		return c.startDate.Format("Jan 2 2006")
+	}
+	return fmt.Sprintf("%v - %v", c.startDate.Format("Jan 2"), c.endDate.Format("Jan 2 2006"))
+}
+
+// openConferences returns every conference that is still selling tickets or taking waitlist spots
+func openConferences() []*Conference {
+	open := make([]*Conference, 0)
+	now := clock.Now()
+	for _, conference := range conferences {
+		if conference.isOpen(now) {
+			open = append(open, conference)
+		}
+	}
+	return open
+}
+
+// findConference looks a conference up by name, nil if there isn't one
+func findConference(name string) *Conference {
+	for _, conference := range conferences {
+		if conference.name == name {
+			return conference
+		}
+	}
+	return nil
+}
+
+// newReference makes a random booking reference like BK-3F9A1C2E
+func newReference() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) //crypto/rand only fails if the OS has no randomness at all
+	}
+	return fmt.Sprintf("BK-%X", b)
+}
+
+// book checks and takes the tickets in one step, prices and saves the booking and returns it with its reference
+func (c *Conference) book(userData UserData) (UserData, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bookLocked(userData)
+}
+
+// bookLocked is book for callers that already hold c.mu
+func (c *Conference) bookLocked(userData UserData) (UserData, error) {
+	if userData.numberOfTickets == 0 || userData.numberOfTickets > c.remainingTickets {
+		return UserData{}, ErrNotEnoughTickets
+	}
+	if err := c.checkCustomerLimit(userData); err != nil {
+		return UserData{}, err
+	}
+
+	discountMu.Lock()
+	defer discountMu.Unlock()
+	discount, err := c.price(&userData, clock.Now())
+	if err != nil {
+		return UserData{}, err
+	}
+	if err := c.assignSeats(&userData); err != nil {
+		return UserData{}, err
+	}
+	userData.reference = newReference()
+	userData.status = bookingConfirmed
+	if userData.duplicateOf = c.duplicateOf(userData); userData.duplicateOf != "" {
+		userData.status = bookingInReview //the tickets are set aside but not sent until someone looks at it
+	}
+
+	//the sale only counts once it is on disk
+	if err := bookingLedger.append(bookedEntry(c.name, userData)); err != nil {
+		return UserData{}, err
+	}
+	c.remainingTickets -= userData.numberOfTickets
+	c.bookings = append(c.bookings, userData)
+	c.takeSeats(userData)
+	auditTrail.record(bookingCreatedEvent(c.name, userData))
+	if discount != nil {
+		discount.used++
+	}
+	return userData, nil
+}
+
+// cancellation is the result of cancelling some or all of a booking's tickets
+type cancellation struct {
+	booking  UserData   //the booking after the cancellation
+	tickets  uint       //how many tickets were cancelled this time
+	refund   int64      //in cents
+	promoted []UserData //bookings made for people on the waitlist with the freed tickets
+}
+
+// cancel gives n of a booking's tickets back to the pool (0 means all of them) and works out the refund.
+// The email has to match the booking so a reference on its own isn't enough to cancel someone else's tickets.
+func (c *Conference) cancel(reference string, email string, n uint) (cancellation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := c.indexOf(reference)
+	if i < 0 || !c.bookings[i].matchesEmail(email) {
+		return cancellation{}, ErrBookingNotFound
+	}
+	booking := &c.bookings[i]
+	active := booking.activeTickets()
+	if active == 0 {
+		return cancellation{}, ErrAlreadyCancelled
+	}
+	if n == 0 {
+		n = active
+	}
+	if n > active {
+		return cancellation{}, ErrTooManyToCancel
+	}
+
+	refund := booking.refundFor(n, refundPolicy.percentAt(clock.Now(), c.startDate))
+	if err := bookingLedger.append(cancelledEntry(c.name, reference, n, refund)); err != nil {
+		return cancellation{}, err
+	}
+	c.freeSeats(*booking, n)
+	booking.applyCancellation(n, refund)
+	c.remainingTickets += n
+	auditTrail.record(bookingCancelledEvent(c.name, *booking, n, refund))
+	//cancelled bookings stay in c.bookings with their status so the history is kept
+	return cancellation{booking: *booking, tickets: n, refund: refund, promoted: c.promoteWaitlist()}, nil
+}
+
+// indexOf finds a booking by reference, -1 if it isn't there, the caller holds c.mu
+func (c *Conference) indexOf(reference string) int {
+	for i, booking := range c.bookings {
+		if booking.reference == reference {
+			return i
+		}
+	}
+	return -1
+}
+
+// snapshot copies what is left and the bookings so they can be read without holding the lock
+func (c *Conference) snapshot() (uint, []UserData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remainingTickets, append([]UserData(nil), c.bookings...)
+}
+
+// findBooking returns the conference a booking reference belongs to, nil if no conference has it
+func findBooking(reference string) *Conference {
+	for _, conference := range conferences {
+		conference.mu.Lock()
+		found := conference.indexOf(reference) >= 0
+		conference.mu.Unlock()
+		if found {
+			return conference
+		}
+	}
+	return nil
+}
+
+// restoreBookings puts every booking from the ledger back on its conference and recounts the tickets left
+func restoreBookings(entries []ledgerEntry) error {
+	for _, entry := range committedEntries(entries) {
+		name := entry.Conference
+		if name == "" {
+			name = conferences[0].name //written before there was more than one conference
+		}
+		conference := findConference(name)
+		if conference == nil {
+			return fmt.Errorf("the ledger has bookings for %q which is not a known conference", name)
+		}
+		switch entry.Type {
+		case entryBooked, "":
+			booking := entry.userData()
+			if booking.tier == "" {
+				//booked before there were tiers, every ticket was a regular one
+				booking.tier = tierRegular
+			}
+			conference.bookings = append(conference.bookings, booking)
+			conference.takeSeats(booking)
+			if booking.waitlist != "" {
+				conference.removeFromWaitlist(booking.waitlist)
+			}
+		case entryWaitlisted:
+			conference.waitlist = append(conference.waitlist, waitlistEntry{
+				reference:       entry.Reference,
+				firstName:       entry.FirstName,
+				lastName:        entry.LastName,
+				email:           entry.Email,
+				numberOfTickets: entry.NumberOfTickets,
+			})
+		case entryCheckedIn:
+			ticket := ticketCode{conference: conference.name, reference: entry.Reference, seat: entry.Seat}
+			conference.checkedIn[ticket.id()] = entry.At
+		case entryCancelled:
+			i := conference.indexOf(entry.Reference)
+			if i < 0 {
+				return fmt.Errorf("the ledger cancels %v which was never booked", entry.Reference)
+			}
+			booking := &conference.bookings[i]
+			n := entry.NumberOfTickets
+			if n == 0 {
+				n = booking.activeTickets() //written before partial cancellations, the whole booking was cancelled
+			}
+			conference.freeSeats(*booking, n)
+			booking.applyCancellation(n, entry.Refund)
+		case entryApproved:
+			i := conference.indexOf(entry.Reference)
+			if i < 0 {
+				return fmt.Errorf("the ledger approves %v which was never booked", entry.Reference)
+			}
+			if conference.bookings[i].status == bookingInReview {
+				conference.bookings[i].applyApproval()
+			}
+		default:
+			return fmt.Errorf("the ledger has an entry of unknown type %q", entry.Type)
+		}
+	}
+	for _, conference := range conferences {
+		conference.remainingTickets = ticketsLeft(conference.tickets, conference.bookings)
+	}
+	restoreDiscountUsage()
+	return nil
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const configPath = "booking.toml"
+
+// config is every setting booking-app runs with. It starts from defaultConfig, then the config file, then
+// BOOKING_* environment variables and then command line flags, each one overriding the ones before it.
+type config struct {
+	httpAddr    string
+	ledger      string
+	auditLog    string
+	ticketKey   string
+	seatMap     string
+	hold        time.Duration
+	maxTickets  uint
+	maxCustomer uint //tickets one customer can have for a conference over all their bookings
+	refund      RefundPolicy
+	email       emailConfig
+	conferences []conferenceConfig //only the config file can change these
+	operator    string             //the token the API wants for review decisions, only OPERATOR_TOKEN sets it
+}
+
+// conferenceConfig is one conference as it is set up in the config file
+type conferenceConfig struct {
+	name      string
+	startDate time.Time
+	endDate   time.Time
+	tiers     []TicketTier //the capacity is the sum of the tier quotas
+}
+
+func defaultConfig() config {
+	return config{
+		ledger:      ledgerPath,
+		auditLog:    auditLogPath,
+		ticketKey:   ticketKeyPath,
+		seatMap:     seatMapPath,
+		hold:        holdDuration,
+		maxTickets:  maxTicketsPerOrder,
+		maxCustomer: maxTicketsPerCustomer,
+		refund:      refundPolicy,
+		email: emailConfig{
+			kind:         "stdout",
+			from:         "Go Conference <tickets@goconference.example>",
+			dir:          "outbox",
+			delay:        10 * time.Second,
+			smtpPort:     587,
+			smtpStartTLS: true,
+		},
+		conferences: []conferenceConfig{
+			{"Go Conference", date(2027, time.April, 14), date(2027, time.April, 16),
+				[]TicketTier{newTier(tierEarlyBird, 9900, 10), newTier(tierRegular, 14900, 35), newTier(tierVIP, 29900, 5)}},
+			{"Gopher Summit", date(2027, time.June, 9), date(2027, time.June, 11),
+				[]TicketTier{newTier(tierEarlyBird, 19900, 20), newTier(tierRegular, 24900, 90), newTier(tierVIP, 49900, 10)}},
+			{"Concurrency Workshop", date(2027, time.September, 22), date(2027, time.September, 22),
+				[]TicketTier{newTier(tierEarlyBird, 5000, 10), newTier(tierRegular, 7500, 20)}},
+		},
+	}
+}
+
+// setting is one plain value that can come from the config file, the environment or a flag
+type setting struct {
+	key    string //in the config file, a dot means it is in a [table], like email.smtp_host
+	flag   string
+	usage  string
+	isBool bool
+	set    func(c *config, value string) error
+	get    func(c *config) string
+}
+
+// env is the environment variable for the setting, like BOOKING_EMAIL_SMTP_HOST
+func (s setting) env() string {
+	return "BOOKING_" + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+func stringSetting(key, flagName, usage string, field func(c *config) *string) setting {
+	return setting{key: key, flag: flagName, usage: usage,
+		set: func(c *config, value string) error { *field(c) = value; return nil },
+		get: func(c *config) string { return *field(c) },
+	}
+}
+
+func intSetting(key, flagName, usage string, field func(c *config) *int) setting {
+	return setting{key: key, flag: flagName, usage: usage,
+		set: func(c *config, value string) error {
+			n, err := strconv.Atoi(value)
+			*field(c) = n
+			return err
+		},
+		get: func(c *config) string { return strconv.Itoa(*field(c)) },
+	}
+}
+
+func int64Setting(key, flagName, usage string, field func(c *config) *int64) setting {
+	return setting{key: key, flag: flagName, usage: usage,
+		set: func(c *config, value string) error {
+			n, err := strconv.ParseInt(value, 10, 64)
+			*field(c) = n
+			return err
+		},
+		get: func(c *config) string { return strconv.FormatInt(*field(c), 10) },
+	}
+}
+
+func uintSetting(key, flagName, usage string, field func(c *config) *uint) setting {
+	return setting{key: key, flag: flagName, usage: usage,
+		set: func(c *config, value string) error {
+			n, err := strconv.ParseUint(value, 10, 32)
+			*field(c) = uint(n)
+			return err
+		},
+		get: func(c *config) string { return strconv.FormatUint(uint64(*field(c)), 10) },
+	}
+}
+
+func boolSetting(key, flagName, usage string, field func(c *config) *bool) setting {
+	return setting{key: key, flag: flagName, usage: usage, isBool: true,
+		set: func(c *config, value string) error {
+			b, err := strconv.ParseBool(value)
+			*field(c) = b
+			return err
+		},
+		get: func(c *config) string { return strconv.FormatBool(*field(c)) },
+	}
+}
+
+func durationSetting(key, flagName, usage string, field func(c *config) *time.Duration) setting {
+	return setting{key: key, flag: flagName, usage: usage,
+		set: func(c *config, value string) error {
+			d, err := time.ParseDuration(value)
+			*field(c) = d
+			return err
+		},
+		get: func(c *config) string { return field(c).String() },
+	}
+}
+
+// settings lists every plain setting, in the order --print-config shows them
+var settings = []setting{
+	stringSetting("http", "http", "serve the booking API on this address (like :8080) instead of the terminal prompts", func(c *config) *string { return &c.httpAddr }),
+	stringSetting("ledger", "ledger", "the file every booking is saved to", func(c *config) *string { return &c.ledger }),
+	stringSetting("audit_log", "audit-log", "the file bookings, cancellations and ticket emails are logged to for the report command", func(c *config) *string { return &c.auditLog }),
+	stringSetting("ticket_key", "ticket-key", "the file the ticket signing key is kept in, unless TICKET_SECRET is set", func(c *config) *string { return &c.ticketKey }),
+	stringSetting("seat_map", "seatmap", "the seat map file, conferences that aren't in it sell general admission", func(c *config) *string { return &c.seatMap }),
+	durationSetting("hold", "hold", "how long tickets are held for before the booking has to be confirmed", func(c *config) *time.Duration { return &c.hold }),
+	uintSetting("max_tickets", "max-tickets", "the most tickets one booking can buy", func(c *config) *uint { return &c.maxTickets }),
+	uintSetting("max_per_customer", "max-per-customer", "the most tickets one email can have for a conference over all its bookings", func(c *config) *uint { return &c.maxCustomer }),
+	intSetting("refund.full_days", "full-refund-days", "cancelling more than this many days before a conference gets a full refund", func(c *config) *int { return &c.refund.fullRefundDays }),
+	int64Setting("refund.late_percent", "late-refund-percent", "percent refunded for cancellations closer to the conference", func(c *config) *int64 { return &c.refund.lateRefundPercent }),
+	stringSetting("email.sender", "email", "how tickets are emailed: stdout, file or smtp", func(c *config) *string { return &c.email.kind }),
+	stringSetting("email.from", "email-from", "the From address on ticket emails", func(c *config) *string { return &c.email.from }),
+	stringSetting("email.dir", "email-dir", "where -email=file writes its .eml files", func(c *config) *string { return &c.email.dir }),
+	durationSetting("email.delay", "email-delay", "how long -email=stdout pretends sending takes", func(c *config) *time.Duration { return &c.email.delay }),
+	stringSetting("email.smtp_host", "smtp-host", "SMTP server host", func(c *config) *string { return &c.email.smtpHost }),
+	intSetting("email.smtp_port", "smtp-port", "SMTP server port", func(c *config) *int { return &c.email.smtpPort }),
+	stringSetting("email.smtp_user", "smtp-user", "SMTP username, leave empty to skip AUTH", func(c *config) *string { return &c.email.smtpUser }),
+	boolSetting("email.smtp_starttls", "smtp-starttls", "upgrade the SMTP connection with STARTTLS", func(c *config) *bool { return &c.email.smtpStartTLS }),
+}
+
+// flagValue remembers what a flag was set to, it is applied after the config file and the environment
+type flagValue struct {
+	setting setting
+	value   *string
+}
+
+func (f flagValue) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f flagValue) Set(value string) error {
+	*f.value = value
+	return nil
+}
+
+func (f flagValue) IsBoolFlag() bool {
+	return f.setting.isBool
+}
+
+// configFlags registers a flag for every setting on fs, the returned map gets the values of the flags that are used
+func configFlags(fs *flag.FlagSet) map[string]*string {
+	defaults := defaultConfig()
+	values := make(map[string]*string)
+	for _, s := range settings {
+		value := new(string)
+		values[s.key] = value
+		fs.Var(flagValue{setting: s, value: value}, s.flag, fmt.Sprintf("%v (default %v, env %v)", s.usage, s.get(&defaults), s.env()))
+	}
+	return values
+}
+
+// loadConfig builds the config from the defaults, the config file at path, the environment and the flags that were set.
+// explicit is true if the path was asked for, a missing default config file is fine.
+func loadConfig(path string, explicit bool, fs *flag.FlagSet, flagValues map[string]*string) (config, error) {
+	c := defaultConfig()
+
+	file, err := os.Open(path)
+	switch {
+	case err == nil:
+		err = readConfigFile(&c, file)
+		file.Close()
+		if err != nil {
+			return config{}, fmt.Errorf("%v: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+	default:
+		return config{}, err
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env()); ok {
+			if err := s.set(&c, value); err != nil {
+				return config{}, fmt.Errorf("%v=%q: %w", s.env(), value, err)
+			}
+		}
+	}
+
+	used := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { used[f.Name] = true })
+	for _, s := range settings {
+		if used[s.flag] {
+			if err := s.set(&c, *flagValues[s.key]); err != nil {
+				return config{}, fmt.Errorf("-%v=%q: %w", s.flag, *flagValues[s.key], err)
+			}
+		}
+	}
+
+	//the password comes from the environment so it doesn't show up in the process list or a config file
+	c.email.smtpPassword = os.Getenv("SMTP_PASSWORD")
+	c.operator = os.Getenv("OPERATOR_TOKEN")
+	return c, c.validate()
+}
+
+// readConfigFile applies a config file on top of c. The file is TOML, the values can be quoted strings, whole numbers
+// and true/false. Durations and dates are strings like "10m" and "2027-04-14".
+//
+//	max_tickets = 4
+//
+//	[email]
+//	sender = "file"
+//
+//	[[conference]]
+//	name = "Staging Drill"
+//	start = "2027-01-10"
+//	end = "2027-01-10"
+//
+//	[[conference.tier]]
+//	name = "regular"
+//	price = 0
+//	quota = 5
+func readConfigFile(c *config, r io.Reader) error {
+	root, err := parseTOML(r)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, s := range settings {
+		known[s.key] = true
+		if value, ok := root.values[s.key]; ok {
+			if err := s.set(c, value); err != nil {
+				return fmt.Errorf("%v = %q: %w", s.key, value, err)
+			}
+		}
+	}
+	for _, key := range sortedKeys(root.values) {
+		if !known[key] {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	for name := range root.arrays {
+		if name != "conference" {
+			return fmt.Errorf("unknown table [[%v]]", name)
+		}
+	}
+
+	tables, ok := root.arrays["conference"]
+	if !ok {
+		return nil
+	}
+	//conferences in the file replace the built in ones, they aren't merged
+	c.conferences = nil
+	for i, table := range tables {
+		conference, err := readConferenceTable(table)
+		if err != nil {
+			return fmt.Errorf("conference %v: %w", i+1, err)
+		}
+		c.conferences = append(c.conferences, conference)
+	}
+	return nil
+}
+
+func readConferenceTable(table *tomlTable) (conferenceConfig, error) {
+	conference := conferenceConfig{name: table.values["name"]}
+	var err error
+	if conference.startDate, err = time.ParseInLocation("2006-01-02", table.values["start"], time.Local); err != nil {
+		return conferenceConfig{}, fmt.Errorf("start should be a date like 2027-04-14")
+	}
+	conference.endDate = conference.startDate
+	if end, ok := table.values["end"]; ok {
+		if conference.endDate, err = time.ParseInLocation("2006-01-02", end, time.Local); err != nil {
+			return conferenceConfig{}, fmt.Errorf("end should be a date like 2027-04-16")
+		}
+	}
+	for _, key := range sortedKeys(table.values) {
+		if key != "name" && key != "start" && key != "end" {
+			return conferenceConfig{}, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	for _, tierTable := range table.arrays["tier"] {
+		price, err := strconv.ParseInt(tierTable.values["price"], 10, 64)
+		if err != nil {
+			return conferenceConfig{}, fmt.Errorf("tier %q: price should be a whole number of cents", tierTable.values["name"])
+		}
+		quota, err := strconv.ParseUint(tierTable.values["quota"], 10, 32)
+		if err != nil {
+			return conferenceConfig{}, fmt.Errorf("tier %q: quota should be a whole number of tickets", tierTable.values["name"])
+		}
+		conference.tiers = append(conference.tiers, newTier(tierTable.values["name"], price, uint(quota)))
+	}
+	return conference, nil
+}
+
+// validate checks the settings make sense together, it reports every problem at once
+func (c config) validate() error {
+	var problems []string
+	if c.hold <= 0 {
+		problems = append(problems, "hold has to be longer than 0")
+	}
+	if c.maxTickets == 0 {
+		problems = append(problems, "max_tickets has to be at least 1")
+	}
+	if c.maxCustomer < c.maxTickets {
+		problems = append(problems, "max_per_customer can't be less than max_tickets")
+	}
+	if c.refund.fullRefundDays < 0 {
+		problems = append(problems, "refund.full_days can't be negative")
+	}
+	if c.refund.lateRefundPercent < 0 || c.refund.lateRefundPercent > 100 {
+		problems = append(problems, "refund.late_percent has to be between 0 and 100")
+	}
+	if c.ledger == "" || c.auditLog == "" {
+		problems = append(problems, "ledger and audit_log need file names")
+	}
+	switch c.email.kind {
+	case "stdout", "file":
+	case "smtp":
+		if c.email.smtpHost == "" {
+			problems = append(problems, "email.smtp_host is needed when email.sender is smtp")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("email.sender %q should be stdout, file or smtp", c.email.kind))
+	}
+	if _, err := mail.ParseAddress(c.email.from); err != nil {
+		problems = append(problems, fmt.Sprintf("email.from %q is not an email address", c.email.from))
+	}
+	if c.email.smtpPort < 1 || c.email.smtpPort > 65535 {
+		problems = append(problems, "email.smtp_port has to be between 1 and 65535")
+	}
+	if c.email.delay < 0 {
+		problems = append(problems, "email.delay can't be negative")
+	}
+
+	if len(c.conferences) == 0 {
+		problems = append(problems, "there has to be at least one conference")
+	}
+	names := make(map[string]bool)
+	for _, conference := range c.conferences {
+		if strings.TrimSpace(conference.name) == "" {
+			problems = append(problems, "every conference needs a name")
+		}
+		if names[conference.name] {
+			problems = append(problems, fmt.Sprintf("there are two conferences called %q", conference.name))
+		}
+		names[conference.name] = true
+		//ticket codes are the conference name, reference and seat joined with |, so a | in the name breaks them
+		if strings.Contains(conference.name, "|") {
+			problems = append(problems, fmt.Sprintf("conference name %q can't have a | in it", conference.name))
+		}
+		if conference.endDate.Before(conference.startDate) {
+			problems = append(problems, fmt.Sprintf("%v ends before it starts", conference.name))
+		}
+		if len(conference.tiers) == 0 {
+			problems = append(problems, fmt.Sprintf("%v needs at least one ticket tier", conference.name))
+		}
+		tierNames := make(map[string]bool)
+		for _, tier := range conference.tiers {
+			if tier.name == "" || tierNames[strings.ToLower(tier.name)] {
+				problems = append(problems, fmt.Sprintf("every tier of %v needs its own name", conference.name))
+			}
+			tierNames[strings.ToLower(tier.name)] = true
+			if tier.price < 0 || tier.quota == 0 {
+				problems = append(problems, fmt.Sprintf("tier %q of %v needs a price of 0 or more and at least 1 ticket", tier.name, conference.name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the configuration has problems:\n  %v", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// apply puts the config into the package level settings the rest of booking-app reads
+func (c config) apply() {
+	maxTicketsPerOrder = c.maxTickets
+	maxTicketsPerCustomer = c.maxCustomer
+	refundPolicy = c.refund
+	holdDuration = c.hold
+	operatorToken = c.operator
+	conferences = make([]*Conference, 0, len(c.conferences))
+	for _, conference := range c.conferences {
+		conferences = append(conferences, newConference(conference.name, conference.startDate, conference.endDate, conference.tiers...))
+	}
+}
+
+// print writes the config in the config file format, so it can be saved and used as a starting point
+func (c config) print(w io.Writer) {
+	table := ""
+	for _, s := range settings {
+		key := s.key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			if key[:i] != table {
+				table = key[:i]
+				fmt.Fprintf(w, "\n[%v]\n", table)
+			}
+			key = key[i+1:]
+		}
+		value := s.get(&c)
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil && value != "true" && value != "false" {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(w, "%v = %v\n", key, value)
+	}
+	if c.email.smtpPassword != "" {
+		fmt.Fprintln(w, "# smtp password is set through SMTP_PASSWORD")
+	}
+	if c.operator != "" {
+		fmt.Fprintln(w, "# operator token is set through OPERATOR_TOKEN")
+	}
+	for _, conference := range c.conferences {
+		fmt.Fprintf(w, "\n[[conference]]\nname = %q\nstart = %q\nend = %q\n",
+			conference.name, conference.startDate.Format("2006-01-02"), conference.endDate.Format("2006-01-02"))
+		for _, tier := range conference.tiers {
+			fmt.Fprintf(w, "\n[[conference.tier]]\nname = %q\nprice = %v\nquota = %v\n", tier.name, tier.price, tier.quota)
+		}
+	}
+}
+
+// tomlTable is a table read from the config file, keys in a [table] are stored as table.key
+type tomlTable struct {
+	values map[string]string
+	arrays map[string][]*tomlTable //[[name]] tables, [[name.sub]] ones are in the arrays of their [[name]] table
+}
+
+func newTOMLTable() *tomlTable {
+	return &tomlTable{values: make(map[string]string), arrays: make(map[string][]*tomlTable)}
+}
+
+// parseTOML decodes a config file and flattens it into a tomlTable
+func parseTOML(r io.Reader) (*tomlTable, error) {
+	var decoded map[string]interface{}
+	if _, err := toml.NewDecoder(r).Decode(&decoded); err != nil {
+		return nil, err
+	}
+	root := newTOMLTable()
+	return root, root.add("", decoded)
+}
+
+// add puts the values of a decoded table in t, the keys in a nested [table] get its name and a dot in front
+func (t *tomlTable) add(prefix string, decoded map[string]interface{}) error {
+	for key, value := range decoded {
+		key = prefix + key
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := t.add(key+".", value); err != nil {
+				return err
+			}
+		case []map[string]interface{}:
+			for _, table := range value {
+				child := newTOMLTable()
+				if err := child.add("", table); err != nil {
+					return err
+				}
+				t.arrays[key] = append(t.arrays[key], child)
+			}
+		case string:
+			t.values[key] = value
+		case int64:
+			t.values[key] = strconv.FormatInt(value, 10)
+		case bool:
+			t.values[key] = strconv.FormatBool(value)
+		default:
+			return fmt.Errorf("%v should be a quoted string, a whole number or true/false", key)
+		}
+	}
+	return nil
+}
+
+// sortedKeys is used to report unknown settings in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
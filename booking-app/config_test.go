@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintedConfigReadsBack(t *testing.T) {
+	c := defaultConfig()
+	c.email.smtpHost = `smtp "quoted" #not a comment`
+	c.maxTickets = 4
+	var printed bytes.Buffer
+	c.print(&printed)
+
+	read := defaultConfig()
+	read.conferences = nil
+	if err := readConfigFile(&read, bytes.NewReader(printed.Bytes())); err != nil {
+		t.Fatalf("reading the printed config: %v", err)
+	}
+	var reprinted bytes.Buffer
+	read.print(&reprinted)
+	if reprinted.String() != printed.String() {
+		t.Errorf("the config changed on the way back in:\n%v\nwant\n%v", reprinted.String(), printed.String())
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"dotted keys", "email.sender = \"file\"\nhold = \"10m\"", ""},
+		{"unknown setting", "bogus = 1", `unknown setting "bogus"`},
+		{"unknown table array", "[[speaker]]\nname = \"ann\"", "unknown table [[speaker]]"},
+		{"float", "max_tickets = 1.5", "should be a quoted string"},
+		{"set twice", "max_tickets = 4\nmax_tickets = 5", "already been defined"},
+		{"bad duration", "hold = \"soon\"", "hold"},
+		{"pipe in a conference name", "[[conference]]\nname = \"Go|Con\"\nstart = \"2027-01-10\"\n[[conference.tier]]\nname = \"regular\"\nprice = 0\nquota = 5", "can't have a | in it"},
+		{"unknown conference setting", "[[conference]]\nname = \"x\"\nstart = \"2027-01-10\"\nvenue = \"hall\"", `unknown setting "venue"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := defaultConfig()
+			err := readConfigFile(&c, strings.NewReader(test.file))
+			if err == nil {
+				err = c.validate()
+			}
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Fatal(err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Fatalf("got error %v, want one with %q", err, test.wantErr)
+			}
+		})
+	}
+
+	c := defaultConfig()
+	if err := readConfigFile(&c, strings.NewReader("[email]\nsender = \"file\" # comment\n[[conference]]\nname = \"Drill\"\nstart = \"2027-01-10\"\n[[conference.tier]]\nname = \"regular\"\nprice = 1_000\nquota = 5\n")); err != nil {
+		t.Fatal(err)
+	}
+	if c.email.kind != "file" || c.hold != holdDuration || c.maxTickets != maxTicketsPerOrder {
+		t.Errorf("settings not in the file changed or the email sender didn't: %+v", c)
+	}
+	if len(c.conferences) != 1 || !c.conferences[0].endDate.Equal(date(2027, time.January, 10)) ||
+		len(c.conferences[0].tiers) != 1 || c.conferences[0].tiers[0].price != 1000 || c.conferences[0].tiers[0].quota != 5 {
+		t.Errorf("conferences read as %+v", c.conferences)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// importColumns are the columns an import file needs in its header row, in any order. discountCode is optional.
+// seats is optional too, the best seats left are picked for rows without them.
+var importColumns = []string{"conference", "firstName", "lastName", "email", "tickets", "tier"}
+
+var exportColumns = []string{"conference", "reference", "firstName", "lastName", "email", "tickets", "tier", "status", "seats"}
+
+// importRow is one attendee read from an import file
+type importRow struct {
+	line       int //line in the file, for error messages
+	conference string
+	booking    UserData
+	problems   ValidationErrors //found while reading the row, before it could be checked
+}
+
+// RowError is every problem with one row of an import file
+type RowError struct {
+	Line     int
+	Problems ValidationErrors
+}
+
+// ImportErrors lists every bad row of an import, nothing is booked if there are any
+type ImportErrors []RowError
+
+func (e ImportErrors) Error() string {
+	rows := make([]string, 0, len(e))
+	for _, row := range e {
+		rows = append(rows, fmt.Sprintf("line %v: %v", row.Line, row.Problems))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// readImportFile reads the attendees from a CSV file with a header row, bookAll checks them
+func readImportFile(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the import file is empty")
+	}
+
+	column := make(map[string]int)
+	for i, name := range records[0] {
+		column[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := column[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("the import file has no %q column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := column[strings.ToLower(name)]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]importRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		var problems ValidationErrors
+		tickets, err := strconv.ParseUint(field(record, "tickets"), 10, 32)
+		if err != nil {
+			problems = ValidationErrors{{"tickets", codeInvalidNumber, "Please enter the number of tickets as a whole number."}}
+		}
+		rows = append(rows, importRow{
+			line:       i + 2, //the header is line 1
+			conference: field(record, "conference"),
+			booking: UserData{
+				firstName:       field(record, "firstName"),
+				lastName:        field(record, "lastName"),
+				email:           field(record, "email"),
+				numberOfTickets: uint(tickets),
+				tier:            field(record, "tier"),
+				discountCode:    field(record, "discountCode"),
+				seats:           parseSeats(field(record, "seats")),
+			},
+			problems: problems,
+		})
+	}
+	return rows, nil
+}
+
+// importedBooking is a booking made by an import
+type importedBooking struct {
+	conference *Conference
+	booking    UserData
+}
+
+// bookAll books every row or none of them. Every conference and the discount codes stay locked for the whole import,
+// so each row is checked against what the rows before it took, and it all goes to the ledger as one batch.
+func bookAll(rows []importRow) ([]importedBooking, error) {
+	//always lock in the same order so two imports can't each hold a conference the other one wants
+	for _, conference := range conferences {
+		conference.mu.Lock()
+		defer conference.mu.Unlock()
+	}
+	discountMu.Lock()
+	defer discountMu.Unlock()
+
+	//remember where everything was so a failed import can be undone
+	bookingCounts := make(map[*Conference]int)
+	remaining := make(map[*Conference]uint)
+	for _, conference := range conferences {
+		bookingCounts[conference] = len(conference.bookings)
+		remaining[conference] = conference.remainingTickets
+	}
+	discountsUsed := make(map[*DiscountCode]uint)
+	for _, discount := range discountCodes {
+		discountsUsed[discount] = discount.used
+	}
+	imported := make([]importedBooking, 0, len(rows))
+	rollback := func() {
+		for _, row := range imported {
+			for _, seat := range row.booking.seats {
+				delete(row.conference.seatsTaken, seat)
+			}
+		}
+		for _, conference := range conferences {
+			conference.bookings = conference.bookings[:bookingCounts[conference]]
+			conference.remainingTickets = remaining[conference]
+		}
+		for discount, used := range discountsUsed {
+			discount.used = used
+		}
+	}
+
+	now := clock.Now()
+	var problems ImportErrors
+	for _, row := range rows {
+		if len(row.problems) > 0 {
+			problems = append(problems, RowError{row.line, row.problems})
+			continue
+		}
+		conference := findConference(row.conference)
+		if conference == nil {
+			problems = append(problems, RowError{row.line, ValidationErrors{{"conference", codeUnknown, fmt.Sprintf("There is no conference called %q.", row.conference)}}})
+			continue
+		}
+		if !conference.isOpen(now) {
+			problems = append(problems, RowError{row.line, ValidationErrors{{"conference", codeClosed, fmt.Sprintf("%v has already started.", conference.name)}}})
+			continue
+		}
+		booking := row.booking
+		if rowProblems := ValidateUserInput(booking.firstName, booking.lastName, booking.email, booking.numberOfTickets, conference.remainingTickets); len(rowProblems) > 0 {
+			problems = append(problems, RowError{row.line, rowProblems})
+			continue
+		}
+		//bookings already imported in this file count towards the limit too, they are in conference.bookings by now
+		if err := conference.checkCustomerLimit(booking); err != nil {
+			problems = append(problems, RowError{row.line, ValidationErrors{customerLimitProblem()}})
+			continue
+		}
+		discount, err := conference.price(&booking, now)
+		if err != nil {
+			problems = append(problems, RowError{row.line, ValidationErrors{priceProblem(err)}})
+			continue
+		}
+		if err := conference.assignSeats(&booking); err != nil {
+			problems = append(problems, RowError{row.line, ValidationErrors{seatProblem(err)}})
+			continue
+		}
+		booking.reference = newReference()
+		booking.status = bookingConfirmed
+		if booking.duplicateOf = conference.duplicateOf(booking); booking.duplicateOf != "" {
+			booking.status = bookingInReview
+		}
+		conference.bookings = append(conference.bookings, booking)
+		conference.takeSeats(booking)
+		conference.remainingTickets -= booking.numberOfTickets
+		if discount != nil {
+			discount.used++
+		}
+		imported = append(imported, importedBooking{conference: conference, booking: booking})
+	}
+	if len(problems) > 0 {
+		rollback()
+		return nil, problems
+	}
+
+	entries := make([]ledgerEntry, 0, len(imported))
+	for _, row := range imported {
+		entries = append(entries, bookedEntry(row.conference.name, row.booking))
+	}
+	if err := bookingLedger.appendBatch(entries); err != nil {
+		rollback()
+		return nil, err
+	}
+	for _, row := range imported {
+		auditTrail.record(bookingCreatedEvent(row.conference.name, row.booking))
+	}
+	return imported, nil
+}
+
+// priceProblem turns an error from pricing a booking into the field it is about
+func priceProblem(err error) FieldError {
+	switch {
+	case errors.Is(err, ErrUnknownTier):
+		return FieldError{"tier", codeUnknown, "Please pick one of the conference's ticket tiers."}
+	case errors.Is(err, ErrTierSoldOut):
+		return FieldError{"tier", codeNotAvailable, "There are not enough tickets left in that tier."}
+	case errors.Is(err, ErrUnknownDiscount), errors.Is(err, ErrDiscountExpired), errors.Is(err, ErrDiscountUsedUp):
+		return FieldError{"discountCode", codeInvalidDiscount, fmt.Sprintf("The discount code can't be used: %v.", err)}
+	}
+	return FieldError{"", "", err.Error()}
+}
+
+// seatProblem turns an error from assigning seats into a problem with the seats field
+func seatProblem(err error) FieldError {
+	code := codeInvalidSeats
+	if errors.Is(err, ErrSeatTaken) || errors.Is(err, ErrNoSeatsLeft) {
+		code = codeNotAvailable
+	}
+	return FieldError{"seats", code, fmt.Sprintf("The seats can't be booked: %v.", err)}
+}
+
+// customerLimitProblem is the problem with a row that takes its customer over maxTicketsPerCustomer
+func customerLimitProblem() FieldError {
+	return FieldError{"tickets", codeTooMany, fmt.Sprintf("One customer can book at most %v tickets for a conference, this email already has some.", maxTicketsPerCustomer)}
+}
+
+// exportBookings writes every booking that still has tickets as CSV, for the venue's attendee list
+func exportBookings(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, conference := range conferences {
+		_, bookings := conference.snapshot()
+		for _, booking := range bookings {
+			if booking.activeTickets() == 0 {
+				continue
+			}
+			err := writer.Write([]string{
+				conference.name,
+				booking.reference,
+				booking.firstName,
+				booking.lastName,
+				booking.email,
+				strconv.FormatUint(uint64(booking.activeTickets()), 10),
+				booking.tier,
+				booking.status,
+				strings.Join(booking.activeSeats(), " "),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrCustomerLimit = errors.New("that would be more tickets than one customer can buy for this conference")
+	ErrNotInReview   = errors.New("booking is not waiting for review")
+)
+
+// maxTicketsPerCustomer caps every booking of one customer for a conference added up, the config can change it
+var maxTicketsPerCustomer uint = 10
+
+// domainsIgnoringDots are mail providers that deliver a.b.c@ and abc@ to the same inbox
+var domainsIgnoringDots = map[string]bool{"gmail.com": true, "googlemail.com": true}
+
+// normalizeEmail turns the different ways of writing one inbox into one key, so First.Last+tickets@Gmail.com and
+// firstlast@gmail.com count as the same customer. Dots are only dropped for providers that ignore them.
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domainsIgnoringDots[domain] {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+// similarEmails is true for two different addresses that are probably the same person: the same inbox written
+// another way, a typo or two in the name part, or the same name part at another domain
+func similarEmails(a string, b string) bool {
+	if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b)) {
+		return false //the same customer booking again, the per customer limit takes care of that
+	}
+	a, b = normalizeEmail(a), normalizeEmail(b)
+	if a == b {
+		return true
+	}
+	localA, domainA, _ := strings.Cut(a, "@")
+	localB, domainB, _ := strings.Cut(b, "@")
+	//dots only separate the same name at most providers, so they don't count when comparing across domains
+	localA, localB = strings.ReplaceAll(localA, ".", ""), strings.ReplaceAll(localB, ".", "")
+	if localA == localB {
+		return true
+	}
+	return domainA == domainB && editDistance(localA, localB) <= 2
+}
+
+// editDistance is how many single character edits turn a into b
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// customerTickets is how many tickets a customer has booked or held for the conference, the caller holds c.mu
+func (c *Conference) customerTickets(email string) uint {
+	customer := normalizeEmail(email)
+	var tickets uint
+	for _, booking := range c.bookings {
+		if normalizeEmail(booking.email) == customer {
+			tickets += booking.activeTickets()
+		}
+	}
+	for _, hold := range c.holds {
+		if normalizeEmail(hold.booking.email) == customer {
+			tickets += hold.booking.numberOfTickets
+		}
+	}
+	return tickets
+}
+
+// checkCustomerLimit makes sure the booking doesn't take the customer over maxTicketsPerCustomer, the caller holds c.mu
+func (c *Conference) checkCustomerLimit(userData UserData) error {
+	if c.customerTickets(userData.email)+userData.numberOfTickets > maxTicketsPerCustomer {
+		return ErrCustomerLimit
+	}
+	return nil
+}
+
+// duplicateOf returns the reference of a booking with the same name and a similar email, "" if there isn't one.
+// The caller holds c.mu.
+func (c *Conference) duplicateOf(userData UserData) string {
+	for _, booking := range c.bookings {
+		if booking.activeTickets() == 0 {
+			continue
+		}
+		sameName := strings.EqualFold(strings.TrimSpace(booking.firstName), strings.TrimSpace(userData.firstName)) &&
+			strings.EqualFold(strings.TrimSpace(booking.lastName), strings.TrimSpace(userData.lastName))
+		if sameName && similarEmails(booking.email, userData.email) {
+			return booking.reference
+		}
+	}
+	return ""
+}
+
+// inReview returns every booking of the conference waiting for review
+func (c *Conference) inReview() []UserData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	review := make([]UserData, 0)
+	for _, booking := range c.bookings {
+		if booking.status == bookingInReview {
+			review = append(review, booking)
+		}
+	}
+	return review
+}
+
+// approve confirms a booking that was held for review, its tickets can be sent after this
+func (c *Conference) approve(reference string) (UserData, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := c.indexOf(reference)
+	if i < 0 {
+		return UserData{}, ErrBookingNotFound
+	}
+	booking := &c.bookings[i]
+	if booking.status != bookingInReview {
+		return UserData{}, ErrNotInReview
+	}
+	if err := bookingLedger.append(approvedEntry(c.name, reference)); err != nil {
+		return UserData{}, err
+	}
+	booking.applyApproval()
+	return *booking, nil
+}
+
+// applyApproval takes a booking out of review, keeping track of any tickets cancelled while it waited
+func (u *UserData) applyApproval() {
+	u.status = bookingConfirmed
+	if u.cancelledTickets > 0 {
+		u.status = bookingPartlyCancelled
+	}
+}
+
+// reject cancels a booking that was held for review with a full refund, the tickets go back on sale
+func (c *Conference) reject(reference string) (cancellation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := c.indexOf(reference)
+	if i < 0 {
+		return cancellation{}, ErrBookingNotFound
+	}
+	booking := &c.bookings[i]
+	if booking.status != bookingInReview {
+		return cancellation{}, ErrNotInReview
+	}
+	n := booking.activeTickets()
+	refund := booking.refundFor(n, 100)
+	if err := bookingLedger.append(cancelledEntry(c.name, reference, n, refund)); err != nil {
+		return cancellation{}, err
+	}
+	c.freeSeats(*booking, n)
+	booking.applyCancellation(n, refund)
+	c.remainingTickets += n
+	auditTrail.record(bookingCancelledEvent(c.name, *booking, n, refund))
+	return cancellation{booking: *booking, tickets: n, refund: refund, promoted: c.promoteWaitlist()}, nil
+}
+
+// approvedEntry is the ledger entry for a booking passing review
+func approvedEntry(conferenceName string, reference string) ledgerEntry {
+	return ledgerEntry{
+		Type:       entryApproved,
+		Reference:  reference,
+		Conference: conferenceName,
+		At:         clock.Now().UTC(),
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const deliveryWorkers = 3
+const deliveryQueueSize = 50
+const maxSendAttempts = 4
+const initialBackoff = 1 * time.Second
+
+// ticketJob is one confirmation that still has to be sent
+type ticketJob struct {
+	conferenceName string
+	userTickets    uint
+	firstName      string
+	lastName       string
+	email          string
+	reference      string
+	tier           string
+	total          int64
+	codes          []string //one signed code per ticket, scanned at check in
+	seats          []string //the seat of each ticket, empty for general admission
+	fromWaitlist   bool
+}
+
+// newTicketJob is the ticket for a booking
+func newTicketJob(conferenceName string, booking UserData) ticketJob {
+	return ticketJob{
+		conferenceName: conferenceName,
+		userTickets:    booking.numberOfTickets,
+		firstName:      booking.firstName,
+		lastName:       booking.lastName,
+		email:          booking.email,
+		reference:      booking.reference,
+		tier:           booking.tier,
+		total:          booking.total,
+		codes:          ticketCodes(conferenceName, booking),
+		seats:          booking.seats,
+		fromWaitlist:   booking.waitlist != "",
+	}
+}
+
+// ticketDelivery is a fixed pool of workers that send tickets off the booking loop
+type ticketDelivery struct {
+	jobs chan ticketJob
+	wg   sync.WaitGroup
+	send func(ticketJob) error
+}
+
+// startTicketDelivery starts the workers, every job they pick up goes through send with retries
+func startTicketDelivery(workers int, send func(ticketJob) error) *ticketDelivery {
+	delivery := &ticketDelivery{
+		jobs: make(chan ticketJob, deliveryQueueSize),
+		send: send,
+	}
+	for i := 0; i < workers; i++ {
+		delivery.wg.Add(1)
+		go delivery.work()
+	}
+	return delivery
+}
+
+// enqueue hands a ticket to the workers, it only blocks if the queue is full
+func (d *ticketDelivery) enqueue(job ticketJob) {
+	d.jobs <- job
+}
+
+// enqueueBooking queues the tickets of a new booking, a booking in review gets them once it is approved.
+// It returns whether they were queued.
+func (d *ticketDelivery) enqueueBooking(conferenceName string, booking UserData) bool {
+	if booking.status == bookingInReview {
+		return false
+	}
+	d.enqueue(newTicketJob(conferenceName, booking))
+	return true
+}
+
+// wait stops taking new jobs and blocks until every queued ticket has been sent or given up on
+func (d *ticketDelivery) wait() {
+	close(d.jobs)
+	d.wg.Wait()
+}
+
+func (d *ticketDelivery) work() {
+	defer d.wg.Done()
+	for job := range d.jobs {
+		backoff := initialBackoff
+		for attempt := 1; ; attempt++ {
+			err := d.send(job)
+			auditTrail.record(sendEvent(job, attempt, err))
+			if err == nil {
+				break
+			}
+			if attempt == maxSendAttempts {
+				fmt.Printf("Giving up on sending %v tickets to %v after %v attempts: %v\n", job.userTickets, job.email, attempt, err)
+				break
+			}
+			//wait a bit longer after every failed attempt
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// emailMessage is one email with a plain text and an HTML version of the same body
+type emailMessage struct {
+	to        string
+	subject   string
+	text      string
+	html      string
+	reference string //the booking it is about, -email=file names the file after it so no address ends up in the name
+}
+
+// EmailSender delivers emails, the ticket workers send every ticket through one
+type EmailSender interface {
+	Send(msg emailMessage) error
+}
+
+// smtpSender sends emails through an SMTP server
+type smtpSender struct {
+	host     string
+	port     int
+	username string //no AUTH is done if this is empty
+	password string
+	from     string
+	startTLS bool //upgrade the connection with STARTTLS before authenticating
+}
+
+// logSender writes emails to w instead of sending them, for development
+type logSender struct {
+	mu    sync.Mutex
+	w     io.Writer
+	delay time.Duration //pretend sending takes this long
+}
+
+// fileSender writes every email as a .eml file in dir, for development
+type fileSender struct {
+	dir  string
+	from string
+}
+
+func (s *smtpSender) Send(msg emailMessage) error {
+	body, err := buildMIME(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.Dial(net.JoinHostPort(s.host, strconv.Itoa(s.port)))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.startTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	//the envelope wants the bare address, not "Name <address>"
+	envelopeFrom, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("from address: %w", err)
+	}
+	if err := client.Mail(envelopeFrom.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.to); err != nil {
+		return err
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(body); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *logSender) Send(msg emailMessage) error {
+	time.Sleep(s.delay)
+	//workers send at the same time, keep each email in one piece
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintln(s.w, "--------------")
+	fmt.Fprintf(s.w, "Sending email to %v: %v\n", msg.to, msg.subject)
+	fmt.Fprint(s.w, msg.text)
+	fmt.Fprintln(s.w, "--------------")
+	return nil
+}
+
+func (s *fileSender) Send(msg emailMessage) error {
+	body, err := buildMIME(s.from, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%v-%v.eml", msg.reference, clock.Now().UTC().Format("20060102T150405.000000000"))
+	return os.WriteFile(filepath.Join(s.dir, name), body, 0644)
+}
+
+// buildMIME turns a message into a multipart/alternative email with the text part first
+func buildMIME(from string, msg emailMessage) ([]byte, error) {
+	//a line break in an address would let it add its own headers
+	if strings.ContainsAny(from+msg.to, "\r\n") {
+		return nil, fmt.Errorf("email address contains a line break")
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	fmt.Fprintf(&body, "From: %v\r\n", from)
+	fmt.Fprintf(&body, "To: %v\r\n", msg.to)
+	fmt.Fprintf(&body, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", msg.subject))
+	fmt.Fprintf(&body, "Date: %v\r\n", clock.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&body, "Content-Type: multipart/alternative; boundary=%v\r\n\r\n", parts.Boundary())
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.text},
+		{"text/html; charset=utf-8", msg.html},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+var ticketTextTemplate = texttemplate.Must(texttemplate.New("ticket.txt").Funcs(texttemplate.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`Hi {{.FirstName}},
+{{if .FromWaitlist}}
+Good news, tickets freed up for you on the waitlist!
+{{end}}
+Here are your {{.Tickets}} tickets for {{.Conference}}.
+
+Name:      {{.FirstName}} {{.LastName}}
+Booking:   {{.Reference}}
+Tier:      {{.Tier}}
+Total:     {{.Total}}
+
+Show one of these codes at the door for each person:
+{{range $i, $code := .Codes}}
+Ticket {{$i | inc}}{{if $.Seats}}, seat {{index $.Seats $i}}{{end}}: {{$code}}{{end}}
+
+See you there!
+`))
+
+var ticketHTMLTemplate = htmltemplate.Must(htmltemplate.New("ticket.html").Parse(`<html>
+<body>
+<p>Hi {{.FirstName}},</p>
+{{if .FromWaitlist}}<p><strong>Good news, tickets freed up for you on the waitlist!</strong></p>
+{{end}}<p>Here are your {{.Tickets}} tickets for <strong>{{.Conference}}</strong>.</p>
+<table>
+<tr><td>Name</td><td>{{.FirstName}} {{.LastName}}</td></tr>
+<tr><td>Booking</td><td>{{.Reference}}</td></tr>
+<tr><td>Tier</td><td>{{.Tier}}</td></tr>
+<tr><td>Total</td><td>{{.Total}}</td></tr>
+</table>
+<p>Show one of these codes at the door for each person:</p>
+<ol>
+{{range $i, $code := .Codes}}<li>{{if $.Seats}}Seat {{index $.Seats $i}}: {{end}}<code>{{$code}}</code></li>
+{{end}}</ol>
+<p>See you there!</p>
+</body>
+</html>
+`))
+
+// ticketEmailData is what the ticket templates can use
+type ticketEmailData struct {
+	Conference   string
+	FirstName    string
+	LastName     string
+	Reference    string
+	Tier         string
+	Tickets      uint
+	Total        string
+	Codes        []string
+	Seats        []string //same order as Codes, empty for general admission
+	FromWaitlist bool
+}
+
+// renderTicketEmail fills the ticket templates in for a job
+func renderTicketEmail(job ticketJob) (emailMessage, error) {
+	data := ticketEmailData{
+		Conference:   job.conferenceName,
+		FirstName:    job.firstName,
+		LastName:     job.lastName,
+		Reference:    job.reference,
+		Tier:         job.tier,
+		Tickets:      job.userTickets,
+		Total:        formatPrice(job.total),
+		Codes:        job.codes,
+		Seats:        job.seats,
+		FromWaitlist: job.fromWaitlist,
+	}
+	var text, html bytes.Buffer
+	if err := ticketTextTemplate.Execute(&text, data); err != nil {
+		return emailMessage{}, err
+	}
+	if err := ticketHTMLTemplate.Execute(&html, data); err != nil {
+		return emailMessage{}, err
+	}
+	return emailMessage{
+		to:        job.email,
+		subject:   fmt.Sprintf("Your tickets for %v", job.conferenceName),
+		text:      text.String(),
+		html:      html.String(),
+		reference: job.reference,
+	}, nil
+}
+
+// emailConfig picks the sender and holds its settings
+type emailConfig struct {
+	kind         string //stdout, file or smtp
+	from         string
+	dir          string
+	delay        time.Duration
+	smtpHost     string
+	smtpPort     int
+	smtpUser     string
+	smtpPassword string
+	smtpStartTLS bool
+}
+
+// newEmailSender builds the sender the config asks for
+func newEmailSender(config emailConfig) (EmailSender, error) {
+	switch config.kind {
+	case "stdout":
+		return &logSender{w: os.Stdout, delay: config.delay}, nil
+	case "file":
+		return &fileSender{dir: config.dir, from: config.from}, nil
+	case "smtp":
+		if config.smtpHost == "" {
+			return nil, fmt.Errorf("smtp email needs a host")
+		}
+		return &smtpSender{
+			host:     config.smtpHost,
+			port:     config.smtpPort,
+			username: config.smtpUser,
+			password: config.smtpPassword,
+			from:     config.from,
+			startTLS: config.smtpStartTLS,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown email sender %q, use stdout, file or smtp", config.kind)
+	}
+}
+
+// ticketSender gives the ticket workers a send function that emails each ticket through sender
+func ticketSender(sender EmailSender) func(ticketJob) error {
+	return func(job ticketJob) error {
+		msg, err := renderTicketEmail(job)
+		if err != nil {
+			return err
+		}
+		return sender.Send(msg)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP is a local listener that speaks just enough SMTP for net/smtp to send one message
+type fakeSMTP struct {
+	listener net.Listener
+	from     string
+	to       string
+	data     chan string
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: listener, data: make(chan string, 1)}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost fake SMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.to = strings.Trim(line[len("RCPT TO:"):], "<> ")
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 end with a line with just a dot")
+			body, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			s.data <- string(body)
+			text.PrintfLine("250 OK")
+		case command == "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSenderDeliversMultipartMessage(t *testing.T) {
+	server := startFakeSMTP(t)
+	addr := server.listener.Addr().(*net.TCPAddr)
+	sender := &smtpSender{host: "127.0.0.1", port: addr.Port, from: "Go Conference <tickets@goconference.example>"}
+
+	msg := emailMessage{to: "ann@example.com", subject: "Your tickets", text: "plain ticket body", html: "<p>html ticket body</p>"}
+	if err := sender.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	body := <-server.data
+	if server.from != "tickets@goconference.example" || server.to != "ann@example.com" {
+		t.Errorf("envelope is from %q to %q", server.from, server.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to := parsed.Header.Get("To"); to != msg.to {
+		t.Errorf("To header is %q", to)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type is %q (%v)", parsed.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	want := []struct{ contentType, content string }{
+		{"text/plain", msg.text},
+		{"text/html", msg.html},
+	}
+	for _, w := range want {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("reading the %v part: %v", w.contentType, err)
+		}
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), w.contentType) {
+			t.Errorf("part is %q, want %v", part.Header.Get("Content-Type"), w.contentType)
+		}
+		content, _ := io.ReadAll(part)
+		if string(content) != w.content {
+			t.Errorf("%v part is %q, want %q", w.contentType, content, w.content)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("want exactly two parts, got more (%v)", err)
+	}
+}
+
+func TestBuildMIMERejectsLineBreaksInAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{"to with CRLF", "tickets@goconference.example", "ann@example.com\r\nBcc: everyone@example.com"},
+		{"to with LF", "tickets@goconference.example", "ann@example.com\nBcc: everyone@example.com"},
+		{"from with CR", "tickets@goconference.example\rBcc: everyone@example.com", "ann@example.com"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := buildMIME(test.from, emailMessage{to: test.to, subject: "Your tickets", text: "text", html: "html"}); err == nil {
+				t.Error("buildMIME accepted an address with a line break")
+			}
+		})
+	}
+	if _, err := buildMIME("tickets@goconference.example", emailMessage{to: "ann@example.com"}); err != nil {
+		t.Errorf("buildMIME rejected plain addresses: %v", err)
+	}
+}
+
+func TestFileSenderNamesFilesByReference(t *testing.T) {
+	fake := useFakeClock(t)
+	dir := t.TempDir()
+	sender := &fileSender{dir: dir, from: "tickets@goconference.example"}
+	msg := emailMessage{to: "ann.lee@example.com", subject: "Your tickets", text: "text", html: "html", reference: "BK-907F9B43"}
+	if err := sender.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "BK-907F9B43-" + fake.Now().UTC().Format("20060102T150405.000000000") + ".eml"
+	if len(files) != 1 || files[0].Name() != want {
+		t.Fatalf("wrote %v, want just %v", files, want)
+	}
+	if strings.Contains(files[0].Name(), "ann") {
+		t.Errorf("the file name %v has the email address in it", files[0].Name())
+	}
+}
@@ -1,3 +1,5 @@
 module booking-app
 
 go 1.20
+
+require github.com/BurntSushi/toml v1.4.0
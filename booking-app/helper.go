@@ -1,13 +1,99 @@
 package main
 
 import (
+	"fmt"
+	"net/mail"
 	"strings"
+	"unicode/utf8"
 )
 
-func ValidateUserInput(firstName string, lastName string, email string, userTickets uint, remainingTickets uint) (bool, bool, bool) {
-	isValidName := len(firstName) >= 2 && len(lastName) >= 2
-	isValidEmail := strings.Contains(email, "@")
-	isValidTicketNumber := userTickets > 0 && userTickets <= remainingTickets
+// codes for the ways a field can fail validation
+const (
+	codeRequired        = "required"
+	codeTooShort        = "too_short"
+	codeTooLong         = "too_long"
+	codeInvalidEmail    = "invalid_email"
+	codeTooMany         = "too_many"
+	codeNotAvailable    = "not_available"
+	codeInvalidNumber   = "invalid_number"
+	codeUnknown         = "unknown"
+	codeClosed          = "closed"
+	codeInvalidDiscount = "invalid_discount"
+	codeInvalidSeats    = "invalid_seats"
+)
+
+const minNameLength = 2
+const maxNameLength = 50
+
+// maxTicketsPerOrder caps a single booking, the config can change it
+var maxTicketsPerOrder uint = 10
+
+// FieldError is one problem with one field of a booking
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is every problem found with a booking, so they can all be shown at once
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, fieldError := range v {
+		messages = append(messages, fieldError.Field+": "+fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ValidateUserInput checks a booking and returns every problem with it, nil if there are none
+func ValidateUserInput(firstName string, lastName string, email string, userTickets uint, remainingTickets uint) ValidationErrors {
+	var problems ValidationErrors
+	problems = append(problems, validateName("firstName", "first name", firstName)...)
+	problems = append(problems, validateName("lastName", "last name", lastName)...)
+	problems = append(problems, validateEmail(email)...)
+	problems = append(problems, validateTickets(userTickets, remainingTickets)...)
+	return problems
+}
+
+// validateName counts characters rather than bytes so names like "Zoë" or "李" are measured correctly
+func validateName(field string, label string, name string) ValidationErrors {
+	length := utf8.RuneCountInString(strings.TrimSpace(name))
+	switch {
+	case length == 0:
+		return ValidationErrors{{field, codeRequired, fmt.Sprintf("Please enter your %v.", label)}}
+	case length < minNameLength:
+		return ValidationErrors{{field, codeTooShort, fmt.Sprintf("Please enter a %v that is more than or equal to %v characters in length.", label, minNameLength)}}
+	case length > maxNameLength:
+		return ValidationErrors{{field, codeTooLong, fmt.Sprintf("Please enter a %v that is at most %v characters in length.", label, maxNameLength)}}
+	}
+	return nil
+}
+
+// validateEmail wants a plain address like someone@example.com, no display name and a dotted domain
+func validateEmail(email string) ValidationErrors {
+	if strings.TrimSpace(email) == "" {
+		return ValidationErrors{{"email", codeRequired, "Please enter your email."}}
+	}
+	address, err := mail.ParseAddress(email)
+	if err == nil && address.Name == "" && address.Address == email {
+		at := strings.LastIndex(email, "@")
+		domain := email[at+1:]
+		if strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".") {
+			return nil
+		}
+	}
+	return ValidationErrors{{"email", codeInvalidEmail, "Please make sure you enter a valid email."}}
+}
 
-	return isValidName, isValidEmail, isValidTicketNumber
+func validateTickets(userTickets uint, remainingTickets uint) ValidationErrors {
+	switch {
+	case userTickets == 0:
+		return ValidationErrors{{"tickets", codeRequired, "Please enter how many tickets you want, at least one."}}
+	case userTickets > maxTicketsPerOrder:
+		return ValidationErrors{{"tickets", codeTooMany, fmt.Sprintf("You can buy at most %v tickets in one order.", maxTicketsPerOrder)}}
+	case userTickets > remainingTickets:
+		return ValidationErrors{{"tickets", codeNotAvailable, fmt.Sprintf("Please make sure you're not trying to purchase more tickets than there are available (%v left).", remainingTickets)}}
+	}
+	return nil
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const holdSweepInterval = 1 * time.Second
+
+// bookingHeld is the status shown for a booking that is only held so far
+const bookingHeld = "held"
+
+var (
+	ErrHoldNotFound = errors.New("hold not found")
+	ErrHoldExpired  = errors.New("hold has expired, the tickets went back on sale")
+	ErrHoldReleased = errors.New("the booking wasn't confirmed, the held tickets went back on sale")
+)
+
+// Clock tells the time, holds, refunds, imports and check-ins go through it so tests can move time along instead of waiting
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clock is where the time comes from for everything that depends on it
+var clock Clock = systemClock{}
+
+// holdDuration is how long held tickets are kept for, the config can change it
+var holdDuration = 10 * time.Minute
+
+// Hold is tickets set aside for a booking that hasn't been confirmed yet. The booking is priced and has its seats,
+// it just isn't sold or in the ledger until it is confirmed. Holds don't survive a restart.
+type Hold struct {
+	reference string
+	booking   UserData
+	expires   time.Time
+}
+
+// newHoldReference makes a random hold reference like HD-3F9A1C2E
+func newHoldReference() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("HD-%X", b)
+}
+
+// hold sets tickets aside for holdDuration, they come off remainingTickets straight away like a booking would
+func (c *Conference) hold(userData UserData) (Hold, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := clock.Now()
+	if userData.numberOfTickets == 0 || userData.numberOfTickets > c.remainingTickets {
+		return Hold{}, ErrNotEnoughTickets
+	}
+	if err := c.checkCustomerLimit(userData); err != nil {
+		return Hold{}, err
+	}
+	//priced now to show the total and check the tier and code, confirm prices it again
+	discountMu.Lock()
+	_, err := c.price(&userData, now)
+	discountMu.Unlock()
+	if err != nil {
+		return Hold{}, err
+	}
+	if err := c.assignSeats(&userData); err != nil {
+		return Hold{}, err
+	}
+
+	hold := Hold{reference: newHoldReference(), booking: userData, expires: now.Add(holdDuration)}
+	c.takeHold(hold)
+	return hold, nil
+}
+
+// confirm turns a hold into a booking, as long as it hasn't expired
+func (c *Conference) confirm(reference string) (UserData, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := c.indexOfHold(reference)
+	if i < 0 {
+		return UserData{}, ErrHoldNotFound
+	}
+	hold := c.holds[i]
+	if !clock.Now().Before(hold.expires) {
+		return UserData{}, ErrHoldExpired //expireHolds gives the tickets back and passes them on to the waitlist
+	}
+	c.releaseHold(i)
+
+	//the held tickets and seats are free again for the moment, so booking them goes through the same checks as any booking.
+	//Nobody else can take them in between because c.mu is held the whole time.
+	booking, err := c.bookLocked(hold.booking)
+	if err != nil {
+		c.takeHold(hold) //the hold is still good until it expires, a discount code might have been used up meanwhile
+		return UserData{}, err
+	}
+	return booking, nil
+}
+
+// release gives a hold's tickets back without booking them
+func (c *Conference) release(reference string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := c.indexOfHold(reference)
+	if i < 0 {
+		return ErrHoldNotFound
+	}
+	c.releaseHold(i)
+	return nil
+}
+
+// expireHolds gives back the tickets of every hold that ran out at now, the caller holds c.mu.
+// It returns the bookings made for people on the waitlist with them.
+func (c *Conference) expireHolds(now time.Time) []UserData {
+	expired := false
+	for i := 0; i < len(c.holds); {
+		if now.Before(c.holds[i].expires) {
+			i++
+			continue
+		}
+		c.releaseHold(i)
+		expired = true
+	}
+	if !expired {
+		return nil
+	}
+	return c.promoteWaitlist()
+}
+
+// takeHold sets the hold's tickets and seats aside, the caller holds c.mu
+func (c *Conference) takeHold(hold Hold) {
+	c.holds = append(c.holds, hold)
+	c.remainingTickets -= hold.booking.numberOfTickets
+	for _, seat := range hold.booking.seats {
+		c.seatsTaken[seat] = hold.reference
+	}
+}
+
+// releaseHold puts the tickets and seats of the i'th hold back on sale, the caller holds c.mu
+func (c *Conference) releaseHold(i int) {
+	hold := c.holds[i]
+	c.holds = append(c.holds[:i], c.holds[i+1:]...)
+	c.remainingTickets += hold.booking.numberOfTickets
+	for _, seat := range hold.booking.seats {
+		delete(c.seatsTaken, seat)
+	}
+}
+
+// indexOfHold finds a hold by reference, -1 if it isn't there, the caller holds c.mu
+func (c *Conference) indexOfHold(reference string) int {
+	for i, hold := range c.holds {
+		if hold.reference == reference {
+			return i
+		}
+	}
+	return -1
+}
+
+// findHold returns the conference a hold reference belongs to, nil if no conference has it
+func findHold(reference string) *Conference {
+	for _, conference := range conferences {
+		conference.mu.Lock()
+		found := conference.indexOfHold(reference) >= 0
+		conference.mu.Unlock()
+		if found {
+			return conference
+		}
+	}
+	return nil
+}
+
+// expireAllHolds runs expireHolds on every conference and sends tickets to anyone it got off the waitlist
+func expireAllHolds(delivery *ticketDelivery) {
+	now := clock.Now()
+	for _, conference := range conferences {
+		conference.mu.Lock()
+		promoted := conference.expireHolds(now)
+		conference.mu.Unlock()
+		for _, booking := range promoted {
+			delivery.enqueue(newTicketJob(conference.name, booking))
+		}
+	}
+}
+
+// startHoldExpiry checks for expired holds every interval until the returned stop function is called
+func startHoldExpiry(interval time.Duration, delivery *ticketDelivery) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				expireAllHolds(delivery)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when the test moves it
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// useFakeClock swaps the package clock for a fake one until the test ends
+func useFakeClock(t *testing.T) *fakeClock {
+	fake := &fakeClock{now: time.Now()}
+	previous := clock
+	clock = fake
+	t.Cleanup(func() { clock = previous })
+	return fake
+}
+
+func testBooking(first string, tickets uint) UserData {
+	return UserData{
+		firstName:       first,
+		lastName:        "Test",
+		email:           first + "@example.com",
+		numberOfTickets: tickets,
+		tier:            tierRegular,
+	}
+}
+
+func TestConfirmBeforeHoldExpires(t *testing.T) {
+	conference := setUpConference(t, 10)
+	fake := useFakeClock(t)
+
+	hold, err := conference.hold(testBooking("ann", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conference.remainingTickets != 7 {
+		t.Errorf("%v tickets left while held, want 7", conference.remainingTickets)
+	}
+	fake.advance(holdDuration - time.Second)
+	booking, err := conference.confirm(hold.reference)
+	if err != nil {
+		t.Fatalf("confirming a second before the hold expires: %v", err)
+	}
+	if booking.status != bookingConfirmed || booking.numberOfTickets != 3 {
+		t.Errorf("booking is %v with %v tickets", booking.status, booking.numberOfTickets)
+	}
+	if conference.remainingTickets != 7 || len(conference.holds) != 0 {
+		t.Errorf("%v tickets left and %v holds after confirming, want 7 and 0", conference.remainingTickets, len(conference.holds))
+	}
+}
+
+func TestConfirmAfterHoldExpires(t *testing.T) {
+	conference := setUpConference(t, 10)
+	fake := useFakeClock(t)
+
+	hold, err := conference.hold(testBooking("ann", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.advance(holdDuration)
+	if _, err := conference.confirm(hold.reference); !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("confirming when the hold expires gave %v, want ErrHoldExpired", err)
+	}
+	if len(conference.bookings) != 0 {
+		t.Errorf("an expired hold was booked")
+	}
+}
+
+func TestExpireHoldsReturnsTicketsAndPromotesWaitlist(t *testing.T) {
+	conference := setUpConference(t, 4)
+	fake := useFakeClock(t)
+
+	//a hold takes every ticket, so the next person has to join the waitlist
+	if _, err := conference.hold(testBooking("ann", 4)); err != nil {
+		t.Fatal(err)
+	}
+	waiting, _, err := conference.joinWaitlist(waitlistEntry{firstName: "bob", lastName: "Test", email: "bob@example.com", numberOfTickets: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conference.mu.Lock()
+	promoted := conference.expireHolds(fake.Now())
+	conference.mu.Unlock()
+	if len(promoted) != 0 || conference.remainingTickets != 0 {
+		t.Fatalf("holds expired early: %v promoted, %v tickets left", len(promoted), conference.remainingTickets)
+	}
+
+	fake.advance(holdDuration)
+	conference.mu.Lock()
+	promoted = conference.expireHolds(fake.Now())
+	conference.mu.Unlock()
+
+	if len(conference.holds) != 0 {
+		t.Errorf("%v holds left after they expired", len(conference.holds))
+	}
+	if len(promoted) != 1 || promoted[0].waitlist != waiting.reference || promoted[0].numberOfTickets != 3 {
+		t.Fatalf("promoted %+v, want bob's 3 tickets from %v", promoted, waiting.reference)
+	}
+	if conference.remainingTickets != 1 {
+		t.Errorf("%v tickets left, want the 1 bob didn't need", conference.remainingTickets)
+	}
+	if len(conference.waitlistSnapshot()) != 0 {
+		t.Errorf("bob is still on the waitlist")
+	}
+}
+
+func TestSessionReadsWhileTheConferenceChanges(t *testing.T) {
+	conference := setUpConference(t, 10)
+	delivery := discardTickets(t)
+
+	//stands in for the hold expiry goroutine, it changes the tickets left and the bookings the whole time the session runs
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			booking, err := conference.book(testBooking("bob", 1))
+			if err == nil {
+				conference.cancel(booking.reference, booking.email, 0)
+			}
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	var out bytes.Buffer
+	script := "1\nann\nlee\nann@example.com\n2\n1\nnone\nyes\n"
+	for i := 0; i < 20; i++ {
+		<-changes //take turns even on one CPU
+		newSession(strings.NewReader(script), &out).run(delivery)
+	}
+	close(stop)
+	<-stopped
+	if !strings.Contains(out.String(), "Thank you ann lee for booking 2") {
+		t.Fatalf("the session didn't book:\n%v", out.String())
+	}
+}
+
+func TestFailedPromotionGoesToTheAuditLog(t *testing.T) {
+	conference := setUpConference(t, 2)
+	fake := useFakeClock(t)
+	auditPath := filepath.Join(t.TempDir(), auditLogPath)
+	audit, err := openAuditLog(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := auditTrail
+	auditTrail = audit
+	t.Cleanup(func() { auditTrail = previous; audit.close() })
+
+	if _, err := conference.hold(testBooking("ann", 2)); err != nil {
+		t.Fatal(err)
+	}
+	waiting, _, err := conference.joinWaitlist(waitlistEntry{firstName: "bob", lastName: "Test", email: "bob@example.com", numberOfTickets: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the promotion can't be written down, so bob has to stay on the waitlist
+	bookingLedger.close()
+	fake.advance(holdDuration)
+	conference.mu.Lock()
+	promoted := conference.expireHolds(fake.Now())
+	conference.mu.Unlock()
+
+	if len(promoted) != 0 || len(conference.waitlistSnapshot()) != 1 {
+		t.Fatalf("%v promoted and %v waiting, want bob still waiting", len(promoted), len(conference.waitlistSnapshot()))
+	}
+	logged, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event auditEvent
+	if err := json.Unmarshal(bytes.TrimSpace(logged), &event); err != nil {
+		t.Fatalf("reading the audit log %q: %v", logged, err)
+	}
+	if event.Type != eventPromoteFailed || event.Waitlist != waiting.reference || !event.At.Equal(fake.Now().UTC()) {
+		t.Errorf("logged %+v, want a promote_failed event for %v at the fake time", event, waiting.reference)
+	}
+}
+
+func TestRefundUsesTheClock(t *testing.T) {
+	conference := setUpConference(t, 10)
+	fake := useFakeClock(t)
+	booking, err := conference.book(testBooking("ann", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the real clock is a month out, the fake one is a day before the conference and inside the late refund window
+	fake.now = conference.startDate.AddDate(0, 0, -1)
+	result, err := conference.cancel(booking.reference, booking.email, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := booking.total * refundPolicy.lateRefundPercent / 100
+	if result.refund != want {
+		t.Errorf("refunded %v, want the late refund of %v", result.refund, want)
+	}
+}
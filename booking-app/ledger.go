@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const ledgerPath = "bookings.jsonl"
+
+// ErrLedgerInUse is returned by openLedger when another booking-app already has the ledger open.
+// Each process keeps its own copy of the bookings, so a second one would sell and check in tickets the first can't see.
+var ErrLedgerInUse = errors.New("the ledger is in use by another booking-app, stop it or go through its API")
+
+// types of ledger entries, entries written before there was a type are bookings
+const (
+	entryBooked     = "booked"
+	entryCancelled  = "cancelled"
+	entryWaitlisted = "waitlisted"
+	entryCheckedIn  = "checkedin"
+	entryApproved   = "approved"  //a booking in review was let through
+	entryCommitted  = "committed" //closes a batch, the batch's entries only count once this is written
+)
+
+// ledgerEntry is one booking, cancellation or waitlist spot as it is written to the ledger file, one JSON object per line.
+// For a cancellation NumberOfTickets is how many tickets were cancelled.
+type ledgerEntry struct {
+	Type            string    `json:"type,omitempty"`
+	Reference       string    `json:"reference,omitempty"`
+	Conference      string    `json:"conference"`
+	FirstName       string    `json:"firstName,omitempty"`
+	LastName        string    `json:"lastName,omitempty"`
+	Email           string    `json:"email,omitempty"`
+	NumberOfTickets uint      `json:"numberOfTickets,omitempty"`
+	Tier            string    `json:"tier,omitempty"`
+	UnitPrice       int64     `json:"unitPrice,omitempty"`
+	DiscountCode    string    `json:"discountCode,omitempty"`
+	Discount        int64     `json:"discount,omitempty"`
+	Total           int64     `json:"total,omitempty"`
+	Refund          int64     `json:"refund,omitempty"`
+	Waitlist        string    `json:"waitlist,omitempty"` //the waitlist reference a booking was promoted from
+	Seat            uint      `json:"seat,omitempty"`     //which ticket of the booking was checked in
+	Batch           string    `json:"batch,omitempty"`    //set on entries that were written together by appendBatch
+	Seats           []string  `json:"seats,omitempty"`
+	ReviewOf        string    `json:"reviewOf,omitempty"` //the booking this one looked like a duplicate of, it waits for review
+	At              time.Time `json:"at"`
+}
+
+// ledger appends every booking to a file so a restart doesn't lose any sales
+type ledger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openLedger opens (or creates) the ledger at path and returns the entries already in it.
+// The ledger stays locked until it is closed, a second process opening it gets ErrLedgerInUse.
+func openLedger(path string) (*ledger, []ledgerEntry, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := lockLedger(file); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	loaded := make([]ledgerEntry, 0)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	var goodBytes int64 //where the last complete entry ends
+	var badLine error
+	for scanner.Scan() {
+		lineNumber++
+		if badLine != nil {
+			//only the very last line can be cut off by a crash, anything bad before that is real damage
+			file.Close()
+			return nil, nil, badLine
+		}
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			goodBytes += int64(len(scanner.Bytes())) + 1
+			continue
+		}
+		var entry ledgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			badLine = fmt.Errorf("%v line %v: %w", path, lineNumber, err)
+			continue
+		}
+		goodBytes += int64(len(scanner.Bytes())) + 1
+		loaded = append(loaded, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if badLine != nil {
+		//a crash cut the last entry off half way, drop it so the next entry starts on a clean line
+		fmt.Printf("Ignoring an unfinished booking at the end of %v\n", path)
+		err = file.Truncate(goodBytes)
+	} else if info.Size() > 0 && info.Size() < goodBytes {
+		//the last entry is complete but lost its newline
+		_, err = file.Write([]byte("\n"))
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return &ledger{file: file}, loaded, nil
+}
+
+// userData turns a booked entry back into a booking
+func (entry ledgerEntry) userData() UserData {
+	status := bookingConfirmed
+	if entry.ReviewOf != "" {
+		status = bookingInReview
+	}
+	return UserData{
+		reference:       entry.Reference,
+		firstName:       entry.FirstName,
+		lastName:        entry.LastName,
+		email:           entry.Email,
+		numberOfTickets: entry.NumberOfTickets,
+		tier:            entry.Tier,
+		unitPrice:       entry.UnitPrice,
+		discountCode:    entry.DiscountCode,
+		discount:        entry.Discount,
+		total:           entry.Total,
+		waitlist:        entry.Waitlist,
+		seats:           entry.Seats,
+		duplicateOf:     entry.ReviewOf,
+		status:          status,
+	}
+}
+
+// bookedEntry is the ledger entry for a new booking
+func bookedEntry(conferenceName string, userData UserData) ledgerEntry {
+	return ledgerEntry{
+		Type:            entryBooked,
+		Reference:       userData.reference,
+		Conference:      conferenceName,
+		FirstName:       userData.firstName,
+		LastName:        userData.lastName,
+		Email:           userData.email,
+		NumberOfTickets: userData.numberOfTickets,
+		Tier:            userData.tier,
+		UnitPrice:       userData.unitPrice,
+		DiscountCode:    userData.discountCode,
+		Discount:        userData.discount,
+		Total:           userData.total,
+		Waitlist:        userData.waitlist,
+		Seats:           userData.seats,
+		ReviewOf:        userData.duplicateOf,
+		At:              clock.Now().UTC(),
+	}
+}
+
+// cancelledEntry is the ledger entry for cancelling n of a booking's tickets
+func cancelledEntry(conferenceName string, reference string, n uint, refund int64) ledgerEntry {
+	return ledgerEntry{
+		Type:            entryCancelled,
+		Reference:       reference,
+		Conference:      conferenceName,
+		NumberOfTickets: n,
+		Refund:          refund,
+		At:              clock.Now().UTC(),
+	}
+}
+
+// append writes the entry as a single line and syncs it to disk before returning
+func (l *ledger) append(entry ledgerEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	//the file is opened with O_APPEND so the whole line goes to the end in one write
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// appendBatch writes all the entries followed by a committed entry in one write.
+// If the process dies part way through, committedEntries drops the unfinished batch on the next start.
+func (l *ledger) appendBatch(entries []ledgerEntry) error {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	batch := hex.EncodeToString(b)
+
+	var lines []byte
+	for _, entry := range append(entries, ledgerEntry{Type: entryCommitted, At: clock.Now().UTC()}) {
+		entry.Batch = batch
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(lines); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// committedEntries leaves out the entries of any batch that never got its committed entry, and the committed entries themselves
+func committedEntries(entries []ledgerEntry) []ledgerEntry {
+	committed := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Type == entryCommitted {
+			committed[entry.Batch] = true
+		}
+	}
+	kept := make([]ledgerEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type == entryCommitted || (entry.Batch != "" && !committed[entry.Batch]) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+func (l *ledger) close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockLedger does nothing where there is no flock, only run one booking-app on a ledger at a time there
+func lockLedger(file *os.File) error {
+	return nil
+}
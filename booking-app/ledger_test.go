@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecondOpenOfTheLedgerIsRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ledgerPath)
+	first, _, err := openLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openLedger(path); !errors.Is(err, ErrLedgerInUse) {
+		t.Fatalf("opening the ledger twice gave %v, want ErrLedgerInUse", err)
+	}
+	first.close()
+	second, _, err := openLedger(path)
+	if err != nil {
+		t.Fatalf("the ledger stayed locked after it was closed: %v", err)
+	}
+	second.close()
+}
@@ -1,23 +1,48 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"time"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
 )
 
 // package level variables defined at the top outside all functions
-var conferenceName = "Go Conference"
-
-const conferenceTickets = 50
-
-var remainingTickets uint = 50
-var bookings = make([]UserData, 0) //a slice/list of strings it's dynamic so size can be 0 and increase by itself
+var bookingLedger *ledger
+
+// a booking is confirmed until some or all of its tickets get cancelled, one that looks like a duplicate
+// of another booking is in review until someone approves or rejects it
+const (
+	bookingConfirmed       = "confirmed"
+	bookingPartlyCancelled = "partly cancelled"
+	bookingCancelled       = "cancelled"
+	bookingInReview        = "in review"
+)
 
 type UserData struct {
-	firstName       string
-	lastName        string
-	email           string
-	numberOfTickets uint
+	reference        string
+	firstName        string
+	lastName         string
+	email            string
+	numberOfTickets  uint
+	tier             string
+	unitPrice        int64 //all prices are in cents
+	discountCode     string
+	discount         int64
+	total            int64
+	status           string
+	cancelledTickets uint
+	refunded         int64
+	waitlist         string   //set if the booking was made for someone on the waitlist
+	seats            []string //one seat label per ticket in ticket order, empty for general admission
+	accessible       bool     //asks for accessible seats when they are picked automatically
+	duplicateOf      string   //the booking this one looks like a duplicate of while it is in review
 	//isOptedInForNewsletter bool
 }
 
@@ -28,62 +53,482 @@ func main() {
 	//%T prints the types of the variables
 	//uint can not be negative
 
-	greetUsers()
+	//settings come from the defaults, then the config file, then BOOKING_* environment variables, then these flags
+	configFile := flag.String("config", configPath, "the config file, BOOKING_CONFIG can set it too")
+	printConfig := flag.Bool("print-config", false, "print the settings booking-app would run with and exit")
+	scriptFile := flag.String("script", "", "replay a recorded session file, one answer per line, instead of asking at the terminal")
+	recordFile := flag.String("record", "", "save every answer typed at the terminal to this file so it can be replayed with -script")
+	flagValues := configFlags(flag.CommandLine)
+	flag.Parse()
+
+	path, explicit := *configFile, false
+	flag.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "config" })
+	if env := os.Getenv("BOOKING_CONFIG"); env != "" && !explicit {
+		path, explicit = env, true
+	}
+	cfg, err := loadConfig(path, explicit, flag.CommandLine, flagValues)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if *printConfig {
+		cfg.print(os.Stdout)
+		return
+	}
+	cfg.apply()
+
+	//booking-app report summarizes the audit log, it doesn't touch the bookings
+	if flag.Arg(0) == "report" {
+		printReport(cfg.auditLog)
+		return
+	}
+
+	//seats have to be known before the bookings sitting in them are restored
+	if err := loadSeatMaps(cfg.seatMap); err != nil {
+		fmt.Printf("Could not load the seat maps: %v\n", err)
+		return
+	}
+
+	//bring back every sale from before a restart, the ledger is the source of truth for what is left
+	var entries []ledgerEntry
+	bookingLedger, entries, err = openLedger(cfg.ledger)
+	if err != nil {
+		fmt.Printf("Could not open the booking ledger: %v\n", err)
+		return
+	}
+	defer bookingLedger.close()
+	auditTrail, err = openAuditLog(cfg.auditLog)
+	if err != nil {
+		fmt.Printf("Could not open the audit log: %v\n", err)
+		return
+	}
+	defer auditTrail.close()
+	if err := restoreBookings(entries); err != nil {
+		fmt.Printf("Could not restore bookings: %v\n", err)
+		return
+	}
+	ticketKey, err = loadTicketKey(cfg.ticketKey)
+	if err != nil {
+		fmt.Printf("Could not load the ticket signing key: %v\n", err)
+		return
+	}
+
+	//booking-app checkin CODE... lets door staff scan tickets without the booking prompts
+	if flag.Arg(0) == "checkin" {
+		checkInCodes(flag.Args()[1:])
+		return
+	}
+	//booking-app export [FILE] writes the attendee list for the venue, to stdout without a file
+	if flag.Arg(0) == "export" {
+		exportTo(flag.Arg(1))
+		return
+	}
+
+	sender, err := newEmailSender(cfg.email)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	//tickets get sent in the background so the next customer doesn't have to wait
+	delivery := startTicketDelivery(deliveryWorkers, ticketSender(sender))
+	defer delivery.wait()
+	//unconfirmed holds go back on sale in the background, this stops before delivery does
+	stopHoldExpiry := startHoldExpiry(holdSweepInterval, delivery)
+	defer stopHoldExpiry()
+
+	//booking-app import FILE books a whole group from a CSV file, the tickets are sent before it exits
+	if flag.Arg(0) == "import" {
+		importFrom(flag.Arg(1), delivery)
+		return
+	}
+	//booking-app review lists the bookings that look like duplicates, review approve|reject REFERENCE decides one.
+	//It can't open the ledger while the API is running, decide over POST /reviews with the operator token then.
+	if flag.Arg(0) == "review" {
+		reviewBookings(flag.Arg(1), flag.Arg(2), delivery)
+		return
+	}
+
+	if cfg.httpAddr != "" {
+		serveHTTP(cfg.httpAddr, delivery)
+		return
+	}
+
+	//-script replays a recorded session file instead of asking at the terminal
+	if *scriptFile != "" {
+		file, err := os.Open(*scriptFile)
+		if err != nil {
+			fmt.Printf("Could not open the script: %v\n", err)
+			return
+		}
+		defer file.Close()
+		newScriptSession(file, os.Stdout).run(delivery)
+		return
+	}
+	var in io.Reader = os.Stdin
+	if *recordFile != "" {
+		file, err := os.Create(*recordFile)
+		if err != nil {
+			fmt.Printf("Could not create the recording: %v\n", err)
+			return
+		}
+		defer file.Close()
+		in = io.TeeReader(os.Stdin, file)
+	}
+	newSession(in, os.Stdout).run(delivery)
+}
+
+// run asks for bookings until every conference has started or the input runs out
+func (s *session) run(delivery *ticketDelivery) {
+	for !s.done {
+
+		open := openConferences()
+		if len(open) == 0 {
+			//end the program
+			fmt.Fprintln(s.out, "All our conferences have started, come back next year, sorry and thank you.")
+			break
+		}
+		conference, wantsCancel := s.chooseConference(open)
+		if s.done {
+			break
+		}
+		if wantsCancel {
+			for _, waitlisted := range s.cancelBooking() {
+				delivery.enqueue(waitlisted)
+			}
+			continue
+		}
+		if conference == nil {
+			fmt.Fprintf(s.out, "Please enter a number between 1 and %v.\n", len(open))
+			continue
+		}
 
-	for {
+		s.greetUsers(conference)
 
-		firstName, lastName, email, userTickets := getUserInput()
-		isValidName, isValidEmail, isValidTicketNumber := ValidateUserInput(firstName, lastName, email, userTickets, remainingTickets)
+		//expiring holds and the waitlist change the conference in the background, so it is only read through snapshot
+		if remaining, _ := conference.snapshot(); remaining == 0 {
+			s.joinWaitlist(conference)
+			continue
+		}
 
-		if isValidName && isValidEmail && isValidTicketNumber {
-			bookTicket(userTickets, firstName, lastName, email)
-			sendTicket(userTickets, firstName, lastName, email)
+		firstName, lastName, email, userTickets := s.getUserInput()
+		if s.done {
+			break
+		}
+		remaining, _ := conference.snapshot()
+		problems := ValidateUserInput(firstName, lastName, email, userTickets, remaining)
+
+		if len(problems) == 0 {
+			tier := s.chooseTier(conference)
+			if tier == "" {
+				fmt.Fprintln(s.out, "Please pick one of the listed ticket tiers.")
+				continue
+			}
+			seats, accessible := s.chooseSeats(conference, userTickets)
+			booking, err := s.bookTicket(conference, UserData{
+				firstName:       firstName,
+				lastName:        lastName,
+				email:           email,
+				numberOfTickets: userTickets,
+				tier:            tier,
+				discountCode:    s.getDiscountCode(),
+				seats:           seats,
+				accessible:      accessible,
+			})
+			if err != nil {
+				fmt.Fprintf(s.out, "Sorry, your booking could not be made: %v\n", err)
+				continue
+			}
+			delivery.enqueueBooking(conference.name, booking)
 
-			firstNames := getFirstNames()
-			fmt.Printf("The first names of the bookings are: %v\n", firstNames)
+			firstNames := getFirstNames(conference)
+			fmt.Fprintf(s.out, "The first names of the bookings are: %v\n", firstNames)
 
-			// fmt.Printf("The whole array: %v\n", bookings)
-			// fmt.Printf("The first value type: %v\n", bookings[0])
-			// fmt.Printf("Array type: %T\n", bookings)
-			// fmt.Printf("Array length: %v\n", len(bookings))
+			// fmt.Fprintf(s.out, "The whole array: %v\n", bookings)
+			// fmt.Fprintf(s.out, "The first value type: %v\n", bookings[0])
+			// fmt.Fprintf(s.out, "Array type: %T\n", bookings)
+			// fmt.Fprintf(s.out, "Array length: %v\n", len(bookings))
 
-			noTicketsRemaining := remainingTickets == 0
+			remaining, _ = conference.snapshot()
+			noTicketsRemaining := remaining == 0
 
 			if noTicketsRemaining {
-				//end the program
-				fmt.Println("All tickets are sold come back next year, sorry and thank you.")
-				break
+				fmt.Fprintf(s.out, "All tickets for %v are sold, anyone else can join the waitlist.\n", conference.name)
 			}
 
 		} else {
-			if !isValidName {
-				fmt.Println("Please enter a name that is more than or equal to two characters in length.")
+			s.printProblems(problems)
+		}
+
+	}
+}
+
+//functions
+
+// checkInCodes checks in every code given, or every line on stdin if there are none so a scanner can be piped in
+func checkInCodes(codes []string) {
+	if len(codes) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if code := strings.TrimSpace(scanner.Text()); code != "" {
+				printCheckIn(code)
 			}
-			if !isValidEmail {
-				fmt.Println("Please make sure you enter a valid email.")
+		}
+		return
+	}
+	for _, code := range codes {
+		printCheckIn(code)
+	}
+}
+
+func printCheckIn(code string) {
+	checkedIn, err := checkIn(code)
+	if err != nil {
+		fmt.Printf("REJECTED: %v\n", err)
+		return
+	}
+	fmt.Printf("OK: %v %v, ticket %v of %v for %v, checked in at %v\n", checkedIn.booking.firstName, checkedIn.booking.lastName,
+		checkedIn.ticket.seat, checkedIn.booking.numberOfTickets, checkedIn.conference.name, checkedIn.at.Format("15:04:05"))
+	if seats := checkedIn.booking.seats; uint(len(seats)) >= checkedIn.ticket.seat {
+		fmt.Printf("    seat %v\n", seats[checkedIn.ticket.seat-1])
+	}
+}
+
+// importFrom books every attendee in a CSV file, or nobody if any row has a problem
+func importFrom(path string, delivery *ticketDelivery) {
+	if path == "" {
+		fmt.Println("Please give the CSV file to import, like: booking-app import attendees.csv")
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Could not open %v: %v\n", path, err)
+		return
+	}
+	defer file.Close()
+
+	rows, err := readImportFile(file)
+	if err == nil {
+		var imported []importedBooking
+		imported, err = bookAll(rows)
+		for _, row := range imported {
+			fmt.Printf("Booked %v %v tickets for %v %v at %v, reference %v\n", row.booking.numberOfTickets, row.booking.tier,
+				row.booking.firstName, row.booking.lastName, row.conference.name, row.booking.reference)
+			if !delivery.enqueueBooking(row.conference.name, row.booking) {
+				fmt.Printf("  %v looks like a duplicate of %v, its tickets wait for review\n", row.booking.reference, row.booking.duplicateOf)
 			}
-			if !isValidTicketNumber {
-				fmt.Println("Please make sure you're not trying to purchase more tickets than there are available.")
+		}
+	}
+	var problems ImportErrors
+	if errors.As(err, &problems) {
+		fmt.Printf("Nothing was imported, %v rows have problems:\n", len(problems))
+		for _, row := range problems {
+			for _, problem := range row.Problems {
+				fmt.Printf("line %v: %v\n", row.Line, problem.Message)
 			}
 		}
+		return
+	}
+	if err != nil {
+		fmt.Printf("Nothing was imported: %v\n", err)
+	}
+}
 
+// reviewBookings lists every booking waiting for review, or approves or rejects the one with the reference
+func reviewBookings(decision string, reference string, delivery *ticketDelivery) {
+	if decision == "" {
+		waiting := 0
+		for _, conference := range conferences {
+			for _, booking := range conference.inReview() {
+				waiting++
+				fmt.Printf("%v %v: %v %v <%v>, %v %v tickets, looks like a duplicate of %v\n", conference.name, booking.reference,
+					booking.firstName, booking.lastName, booking.email, booking.activeTickets(), booking.tier, booking.duplicateOf)
+			}
+		}
+		fmt.Printf("%v bookings waiting for review\n", waiting)
+		return
+	}
+	conference := findBooking(reference)
+	if conference == nil {
+		fmt.Printf("No booking %q\n", reference)
+		return
+	}
+	switch decision {
+	case "approve":
+		booking, err := conference.approve(reference)
+		if err != nil {
+			fmt.Printf("Could not approve %v: %v\n", reference, err)
+			return
+		}
+		delivery.enqueue(newTicketJob(conference.name, booking))
+		fmt.Printf("Approved %v, the tickets are on their way to %v\n", reference, booking.email)
+	case "reject":
+		result, err := conference.reject(reference)
+		if err != nil {
+			fmt.Printf("Could not reject %v: %v\n", reference, err)
+			return
+		}
+		for _, waitlisted := range result.promoted {
+			delivery.enqueue(newTicketJob(conference.name, waitlisted))
+		}
+		fmt.Printf("Rejected %v, %v tickets went back on sale and %v is refunded\n", reference, result.tickets, formatPrice(result.refund))
+	default:
+		fmt.Println("Use review to list the bookings, or review approve|reject REFERENCE")
 	}
 }
 
-//functions
+// exportTo writes the attendee list to path, or to stdout if path is empty
+func exportTo(path string) {
+	if path == "" {
+		if err := exportBookings(os.Stdout); err != nil {
+			fmt.Printf("Could not export the bookings: %v\n", err)
+		}
+		return
+	}
+	file, err := os.Create(path)
+	if err == nil {
+		err = exportBookings(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Printf("Could not export the bookings: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported the bookings to %v\n", path)
+}
 
-func greetUsers() {
-	fmt.Printf("Welcome to %v booking application\n", conferenceName)
-	fmt.Printf("We have a total of %v tickets and %v are still available.\n", conferenceTickets, remainingTickets)
-	fmt.Println("Get your tickets here to attend.")
+// serveHTTP runs the booking API until the process is interrupted
+func serveHTTP(addr string, delivery *ticketDelivery) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	server := &http.Server{Addr: addr, Handler: newServer(delivery)}
+	shutDown := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		//stop taking requests so the tickets already booked can still be sent before we exit
+		server.Shutdown(context.Background())
+		close(shutDown)
+	}()
+
+	fmt.Printf("Booking API listening on %v\n", addr)
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Booking API stopped: %v\n", err)
+		return
+	}
+	//ListenAndServe returns as soon as Shutdown starts, the handlers still running may be queueing tickets
+	//and delivery.wait closes the queue once we return
+	<-shutDown
+}
+
+func (s *session) greetUsers(conference *Conference) {
+	fmt.Fprintf(s.out, "Welcome to %v booking application\n", conference.name)
+	remaining, _ := conference.snapshot()
+	fmt.Fprintf(s.out, "We have a total of %v tickets and %v are still available.\n", conference.tickets, remaining)
+	fmt.Fprintln(s.out, "Get your tickets here to attend.")
 }
 
-func getFirstNames() []string {
+// chooseConference lists the open conferences and returns the one the user picks, nil if the choice isn't on the list.
+// The second result is true if the user wants to cancel a booking instead.
+func (s *session) chooseConference(open []*Conference) (*Conference, bool) {
+	fmt.Fprintln(s.out, "Upcoming conferences:")
+	for i, conference := range open {
+		remaining, _ := conference.snapshot()
+		if remaining == 0 {
+			fmt.Fprintf(s.out, "%v. %v (%v) - sold out, join the waitlist\n", i+1, conference.name, conference.dates())
+			continue
+		}
+		fmt.Fprintf(s.out, "%v. %v (%v) - %v of %v tickets left\n", i+1, conference.name, conference.dates(), remaining, conference.tickets)
+	}
+	fmt.Fprintln(s.out, "0. Cancel a booking")
+
+	choice := s.askNumber("Pick a conference: ")
+	fmt.Fprintln(s.out)
+
+	if choice == 0 {
+		return nil, true
+	}
+	if choice < 1 || choice > len(open) {
+		return nil, false
+	}
+	return open[choice-1], false
+}
+
+// cancelBooking asks for a booking reference and email and cancels some or all of its tickets.
+// It returns the tickets to send to anyone on the waitlist who got the freed tickets.
+func (s *session) cancelBooking() []ticketJob {
+	reference := s.ask("Enter your booking reference: ")
+	email := s.ask("\nEnter the email you booked with: ")
+	tickets := s.askCount("\nEnter how many tickets to cancel (0 for all of them): ")
+	fmt.Fprintln(s.out)
+	if s.done {
+		return nil
+	}
+
+	conference := findBooking(strings.ToUpper(reference))
+	if conference == nil {
+		fmt.Fprintln(s.out, "Sorry, we could not find that booking.")
+		return nil
+	}
+	result, err := conference.cancel(strings.ToUpper(reference), email, tickets)
+	if errors.Is(err, ErrBookingNotFound) {
+		fmt.Fprintln(s.out, "Sorry, we could not find that booking.")
+		return nil
+	}
+	if err != nil {
+		fmt.Fprintf(s.out, "Sorry, your booking could not be cancelled: %v\n", err)
+		return nil
+	}
+
+	fmt.Fprintf(s.out, "Cancelled %v tickets for %v, your refund is %v\n", result.tickets, conference.name, formatPrice(result.refund))
+	if left := result.booking.activeTickets(); left > 0 {
+		fmt.Fprintf(s.out, "You still have %v tickets on booking %v\n", left, result.booking.reference)
+	}
+
+	jobs := make([]ticketJob, 0, len(result.promoted))
+	for _, waitlisted := range result.promoted {
+		jobs = append(jobs, newTicketJob(conference.name, waitlisted))
+	}
+	return jobs
+}
+
+// joinWaitlist asks for the user's details and puts them in the queue for a sold out conference
+func (s *session) joinWaitlist(conference *Conference) {
+	fmt.Fprintf(s.out, "%v is sold out, but you can join the waitlist and get tickets as soon as some free up.\n", conference.name)
+	firstName, lastName, email, userTickets := s.getUserInput()
+	if s.done {
+		return
+	}
+	if problems := ValidateUserInput(firstName, lastName, email, userTickets, conference.tickets); len(problems) > 0 {
+		s.printProblems(problems)
+		return
+	}
+
+	entry, position, err := conference.joinWaitlist(waitlistEntry{firstName: firstName, lastName: lastName, email: email, numberOfTickets: userTickets})
+	if err != nil {
+		fmt.Fprintf(s.out, "Sorry, you could not join the waitlist: %v\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "You are number %v on the waitlist for %v, your waitlist reference is %v\n", position, conference.name, entry.reference)
+}
+
+// printProblems shows every validation problem at once so they can all be fixed in one go
+func (s *session) printProblems(problems ValidationErrors) {
+	for _, problem := range problems {
+		fmt.Fprintln(s.out, problem.Message)
+	}
+}
+
+func getFirstNames(conference *Conference) []string {
 	firstNames := []string{}
 	//to iterate through a slice we need a range expression
 	//for arrays and slices, range provides the index and value for each element
 	//this is a nested for loop below
 
+	_, bookings := conference.snapshot()
 	for _, booking := range bookings {
 		//strings.Fields() splits the string with white space as a separator
 		//var names = strings.Fields(booking) //this names will be an array containing the first name and the last name as separate strings ((to extract from a slice)
@@ -92,52 +537,114 @@ func getFirstNames() []string {
 	return firstNames
 }
 
-func getUserInput() (string, string, string, uint) {
+func (s *session) getUserInput() (string, string, string, uint) {
 
-	var firstName string
-	var lastName string
-	var email string
-	var userTickets uint
+	//ask user for their name and tickets, a whole line each so names can have spaces in them
+	firstName := s.ask("Enter your first name: ")
+	lastName := s.ask("\nEnter your last name: ")
+	email := s.ask("\nEnter your email: ")
+	userTickets := s.askCount("\nEnter the amount of tickets to purchase: ")
 
-	//ask user for their name and tickets
-	fmt.Print("Enter your first name: ")
-	fmt.Scan(&firstName)
+	return firstName, lastName, email, userTickets
+}
 
-	fmt.Print("\nEnter your last name: ")
-	fmt.Scan(&lastName)
+// ticketsLeft works out how many of the tickets are still available after the given bookings
+func ticketsLeft(tickets uint, bookings []UserData) uint {
+	var sold uint
+	for _, booking := range bookings {
+		sold += booking.activeTickets()
+	}
+	if sold >= tickets {
+		return 0
+	}
+	return tickets - sold
+}
 
-	fmt.Print("\nEnter your email: ")
-	fmt.Scan(&email)
+// chooseTier lists the conference's ticket tiers and returns the name of the one the user picks, "" if the choice isn't on the list
+func (s *session) chooseTier(conference *Conference) string {
+	fmt.Fprintln(s.out, "\nTicket tiers:")
+	conference.mu.Lock()
+	for i := range conference.tiers {
+		tier := &conference.tiers[i]
+		fmt.Fprintf(s.out, "%v. %v - %v each, %v left\n", i+1, tier.name, formatPrice(tier.price), conference.tierRemaining(tier))
+	}
+	conference.mu.Unlock()
 
-	fmt.Print("\nEnter the amount of tickets to purchase: ")
-	fmt.Scan(&userTickets)
+	choice := s.askNumber("Pick a ticket tier: ")
 
-	return firstName, lastName, email, userTickets
+	if choice < 1 || choice > len(conference.tiers) {
+		return ""
+	}
+	return conference.tiers[choice-1].name
 }
 
-func bookTicket(userTickets uint, firstName string, lastName string, email string) {
-	//arrays in go have a fixed size
-	remainingTickets = remainingTickets - userTickets
+// chooseSeats shows the seat map and asks which seats to book, no seats means the best ones left get picked.
+// It doesn't ask anything for general admission conferences.
+func (s *session) chooseSeats(conference *Conference, userTickets uint) ([]string, bool) {
+	if conference.seatMap == nil {
+		return nil, false
+	}
+	fmt.Fprintln(s.out)
+	renderSeatMap(s.out, conference)
+
+	choice := s.ask(fmt.Sprintf("\nEnter %v seats like A5,A6, best for the best seats left or accessible for accessible seats: ", userTickets))
+	switch strings.ToLower(choice) {
+	case "best", "":
+		return nil, false
+	case "accessible":
+		return nil, true
+	}
+	return parseSeats(choice), false
+}
 
-	//create a struct for a user
-	var userData = UserData{
-		firstName:       firstName,
-		lastName:        lastName,
-		email:           email,
-		numberOfTickets: userTickets,
+func (s *session) getDiscountCode() string {
+	code := s.ask("\nEnter a discount code (or none): ")
+	if strings.EqualFold(code, "none") {
+		return ""
 	}
+	return code
+}
 
-	bookings = append(bookings, userData)
-	fmt.Printf("List of bookings is %v\n", bookings)
+// bookTicket holds the tickets, shows what they cost and books them once the user confirms
+func (s *session) bookTicket(conference *Conference, userData UserData) (UserData, error) {
+	//checking and taking the tickets happens in one step so nobody else can grab them in between
+	hold, err := conference.hold(userData)
+	if err != nil {
+		return UserData{}, err
+	}
+	held := hold.booking
+	fmt.Fprintf(s.out, "\n%v %v tickets are held for you until %v\n", held.numberOfTickets, held.tier, hold.expires.Format("15:04:05"))
+	if len(held.seats) > 0 {
+		fmt.Fprintf(s.out, "Seats: %v\n", strings.Join(held.seats, ", "))
+	}
+	fmt.Fprintf(s.out, "Total: %v\n", formatPrice(held.total))
 
-	fmt.Printf("Thank you %v %v for booking %v tickets. You will receive a confirmation email at %v\n", firstName, lastName, userTickets, email)
-	fmt.Printf("%v tickets remaining for %v\n", remainingTickets, conferenceName)
-}
+	answer := s.ask("Type yes to confirm your booking: ")
+	fmt.Fprintln(s.out)
+	if !strings.EqualFold(answer, "yes") {
+		conference.release(hold.reference)
+		return UserData{}, ErrHoldReleased
+	}
+	userData, err = conference.confirm(hold.reference)
+	if err != nil {
+		return UserData{}, err
+	}
+	remainingTickets, _ := conference.snapshot()
 
-func sendTicket(userTickets uint, firstName string, lastName string, email string) {
-	time.Sleep(10 * time.Second)
-	var ticket = fmt.Sprintf("%v tickets for %v %v", userTickets, firstName, lastName)
-	fmt.Println("--------------")
-	fmt.Printf("Sending ticket:\n %v to email address %v", ticket, email)
-	fmt.Println("\n--------------")
+	if userData.status == bookingInReview {
+		fmt.Fprintf(s.out, "Thank you %v %v for booking %v %v tickets. We already have a booking under a very similar name and email, so we will check this one and email your tickets to %v once it has been approved.\n", userData.firstName, userData.lastName, userData.numberOfTickets, userData.tier, userData.email)
+	} else {
+		fmt.Fprintf(s.out, "Thank you %v %v for booking %v %v tickets. You will receive a confirmation email at %v\n", userData.firstName, userData.lastName, userData.numberOfTickets, userData.tier, userData.email)
+	}
+	fmt.Fprintf(s.out, "%v x %v", userData.numberOfTickets, formatPrice(userData.unitPrice))
+	if userData.discount > 0 {
+		fmt.Fprintf(s.out, " - %v (%v)", formatPrice(userData.discount), userData.discountCode)
+	}
+	fmt.Fprintf(s.out, " = %v\n", formatPrice(userData.total))
+	fmt.Fprintf(s.out, "Your booking reference is %v\n", userData.reference)
+	if len(userData.seats) > 0 {
+		fmt.Fprintf(s.out, "Your seats are %v\n", strings.Join(userData.seats, ", "))
+	}
+	fmt.Fprintf(s.out, "%v tickets remaining for %v\n", remainingTickets, conference.name)
+	return userData, nil
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prices are whole cents so adding them up never picks up float rounding errors
+const currencySymbol = "$"
+
+const (
+	tierEarlyBird = "early bird"
+	tierRegular   = "regular"
+	tierVIP       = "vip"
+)
+
+var (
+	ErrUnknownTier     = errors.New("unknown ticket tier")
+	ErrTierSoldOut     = errors.New("not enough tickets left in that tier")
+	ErrUnknownDiscount = errors.New("unknown discount code")
+	ErrDiscountExpired = errors.New("discount code has expired")
+	ErrDiscountUsedUp  = errors.New("discount code has been used the maximum number of times")
+)
+
+// TicketTier is one kind of ticket for a conference with its own price and quota
+type TicketTier struct {
+	name  string
+	price int64 //in cents
+	quota uint
+}
+
+func newTier(name string, price int64, quota uint) TicketTier {
+	return TicketTier{name: name, price: price, quota: quota}
+}
+
+// DiscountCode takes either a percentage or a fixed amount off an order
+type DiscountCode struct {
+	code       string
+	percentOff int64 //0 to 100, used when amountOff is 0
+	amountOff  int64 //in cents, taken off the whole order
+	expires    time.Time
+	usageLimit uint //0 means no limit
+	used       uint
+}
+
+// discountCodes holds every code that can be entered, keyed by the upper case code
+var discountCodes = map[string]*DiscountCode{
+	"GOPHER10":  {code: "GOPHER10", percentOff: 10, expires: date(2027, time.March, 1), usageLimit: 100},
+	"SPEAKER":   {code: "SPEAKER", percentOff: 100, expires: date(2027, time.December, 31), usageLimit: 20},
+	"STUDENT25": {code: "STUDENT25", amountOff: 2500, expires: date(2027, time.June, 1)},
+}
+
+// discountMu guards the usage counts on discountCodes, it is always taken after a Conference lock
+var discountMu sync.Mutex
+
+// findTier returns the tier with that name (any case), nil if the conference doesn't sell it
+func (c *Conference) findTier(name string) *TicketTier {
+	for i := range c.tiers {
+		if strings.EqualFold(c.tiers[i].name, name) {
+			return &c.tiers[i]
+		}
+	}
+	return nil
+}
+
+// tierRemaining works out how many tickets of a tier are left, the caller holds c.mu
+func (c *Conference) tierRemaining(tier *TicketTier) uint {
+	var sold uint
+	for _, booking := range c.bookings {
+		if booking.tier == tier.name {
+			sold += booking.activeTickets()
+		}
+	}
+	for _, hold := range c.holds {
+		if hold.booking.tier == tier.name {
+			sold += hold.booking.numberOfTickets
+		}
+	}
+	if sold >= tier.quota {
+		return 0
+	}
+	return tier.quota - sold
+}
+
+// lookupDiscount finds a code that can still be used at now, the caller holds discountMu
+func lookupDiscount(code string, now time.Time) (*DiscountCode, error) {
+	discount, ok := discountCodes[strings.ToUpper(code)]
+	if !ok {
+		return nil, ErrUnknownDiscount
+	}
+	if !now.Before(discount.expires) {
+		return nil, ErrDiscountExpired
+	}
+	if discount.usageLimit > 0 && discount.used >= discount.usageLimit {
+		return nil, ErrDiscountUsedUp
+	}
+	return discount, nil
+}
+
+// amountFor works out how much the code takes off an order with that subtotal, never more than the subtotal
+func (d *DiscountCode) amountFor(subtotal int64) int64 {
+	off := d.amountOff
+	if off == 0 {
+		off = subtotal * d.percentOff / 100
+	}
+	if off > subtotal {
+		return subtotal
+	}
+	return off
+}
+
+// price fills in the tier price, discount and total on a booking, the caller holds c.mu and discountMu
+func (c *Conference) price(userData *UserData, now time.Time) (*DiscountCode, error) {
+	tier := c.findTier(userData.tier)
+	if tier == nil {
+		return nil, ErrUnknownTier
+	}
+	if userData.numberOfTickets > c.tierRemaining(tier) {
+		return nil, ErrTierSoldOut
+	}
+	userData.tier = tier.name
+	userData.unitPrice = tier.price
+	subtotal := tier.price * int64(userData.numberOfTickets)
+
+	var discount *DiscountCode
+	userData.discount = 0
+	if userData.discountCode != "" {
+		var err error
+		discount, err = lookupDiscount(userData.discountCode, now)
+		if err != nil {
+			return nil, err
+		}
+		userData.discountCode = discount.code
+		userData.discount = discount.amountFor(subtotal)
+	}
+	userData.total = subtotal - userData.discount
+	return discount, nil
+}
+
+// restoreDiscountUsage counts how often each code was used by the bookings in the ledger
+func restoreDiscountUsage() {
+	for _, conference := range conferences {
+		for _, booking := range conference.bookings {
+			if discount, ok := discountCodes[booking.discountCode]; ok {
+				discount.used++
+			}
+		}
+	}
+}
+
+// formatPrice shows cents as dollars, like 14900 as $149.00
+func formatPrice(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%v%v%d.%02d", sign, currencySymbol, cents/100, cents%100)
+}
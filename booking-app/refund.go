@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrTooManyToCancel = errors.New("the booking doesn't have that many tickets left to cancel")
+
+// RefundPolicy decides how much of the price comes back when tickets are cancelled
+type RefundPolicy struct {
+	fullRefundDays    int   //cancelling more than this many days before the start gets everything back
+	lateRefundPercent int64 //what percent comes back after that, up until the conference starts
+}
+
+// refundPolicy is the policy used for every cancellation, the config can change it
+var refundPolicy = RefundPolicy{fullRefundDays: 7, lateRefundPercent: 50}
+
+// percentAt is how much of the price is refunded when cancelling at now for a conference starting at start
+func (p RefundPolicy) percentAt(now time.Time, start time.Time) int64 {
+	if !now.Before(start) {
+		return 0
+	}
+	if start.Sub(now) > time.Duration(p.fullRefundDays)*24*time.Hour {
+		return 100
+	}
+	return p.lateRefundPercent
+}
+
+// activeTickets is how many tickets of a booking haven't been cancelled
+func (u UserData) activeTickets() uint {
+	if u.status == bookingCancelled {
+		return 0
+	}
+	return u.numberOfTickets - u.cancelledTickets
+}
+
+// paidFor is what the booking paid for its first n tickets, splitting the total this way
+// means the refunds for every ticket add up to exactly the total even when it doesn't divide evenly
+func (u UserData) paidFor(n uint) int64 {
+	if u.numberOfTickets == 0 {
+		return 0
+	}
+	return u.total * int64(n) / int64(u.numberOfTickets)
+}
+
+// refundFor works out the refund for cancelling n more tickets of a booking at the given percent
+func (u UserData) refundFor(n uint, percent int64) int64 {
+	paid := u.paidFor(u.cancelledTickets+n) - u.paidFor(u.cancelledTickets)
+	return paid * percent / 100
+}
+
+// applyCancellation takes n tickets off a booking and marks it cancelled once none are left.
+// A booking in review stays in review for the tickets it still has.
+func (u *UserData) applyCancellation(n uint, refund int64) {
+	u.cancelledTickets += n
+	u.refunded += refund
+	if u.cancelledTickets >= u.numberOfTickets {
+		u.status = bookingCancelled
+	} else if u.status != bookingInReview {
+		u.status = bookingPartlyCancelled
+	}
+}
+
+// matchesEmail is how a booking owner proves it is theirs when cancelling
+func (u UserData) matchesEmail(email string) bool {
+	return strings.EqualFold(strings.TrimSpace(u.email), strings.TrimSpace(email))
+}
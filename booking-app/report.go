@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// daySales is what was sold and cancelled on one day
+type daySales struct {
+	bookings  int
+	tickets   uint
+	cancelled uint
+	revenue   int64
+	refunds   int64
+}
+
+// salesReport is what the report command works out from the audit log
+type salesReport struct {
+	days           map[string]*daySales //keyed by date like 2027-04-14
+	domainTickets  map[string]int64     //tickets still booked per email domain
+	soldTickets    map[string]int64     //tickets still booked per conference
+	sent           int
+	failedAttempts int
+	gaveUp         []auditEvent
+	skipped        int //lines of the log that couldn't be read
+}
+
+// readAuditLog reads every event in the log, lines that aren't events are counted and skipped
+func readAuditLog(path string) ([]auditEvent, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	events := make([]auditEvent, 0)
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event auditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			skipped++
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, skipped, scanner.Err()
+}
+
+func buildReport(events []auditEvent) salesReport {
+	report := salesReport{
+		days:          make(map[string]*daySales),
+		domainTickets: make(map[string]int64),
+		soldTickets:   make(map[string]int64),
+	}
+	day := func(at time.Time) *daySales {
+		key := at.Local().Format("2006-01-02")
+		if report.days[key] == nil {
+			report.days[key] = &daySales{}
+		}
+		return report.days[key]
+	}
+	for _, event := range events {
+		switch event.Type {
+		case eventBookingCreated:
+			sales := day(event.At)
+			sales.bookings++
+			sales.tickets += event.Tickets
+			sales.revenue += event.Total
+			report.domainTickets[emailDomain(event.Email)] += int64(event.Tickets)
+			report.soldTickets[event.Conference] += int64(event.Tickets)
+		case eventBookingCancelled:
+			sales := day(event.At)
+			sales.cancelled += event.Tickets
+			sales.refunds += event.Refund
+			report.domainTickets[emailDomain(event.Email)] -= int64(event.Tickets)
+			report.soldTickets[event.Conference] -= int64(event.Tickets)
+		case eventTicketSent:
+			report.sent++
+		case eventSendFailed:
+			report.failedAttempts++
+			if event.GaveUp {
+				report.gaveUp = append(report.gaveUp, event)
+			}
+		}
+	}
+	return report
+}
+
+// print writes the report as tables, sell-through is worked out against the capacity of the configured conferences
+func (r salesReport) print(w io.Writer) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Sales by day")
+	fmt.Fprintln(table, "Date\tBookings\tTickets\tCancelled\tRevenue\tRefunds\t")
+	dates := make([]string, 0, len(r.days))
+	for date := range r.days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	var total daySales
+	for _, date := range dates {
+		sales := r.days[date]
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\t%v\t\n", date, sales.bookings, sales.tickets, sales.cancelled, formatPrice(sales.revenue), formatPrice(sales.refunds))
+		total.bookings += sales.bookings
+		total.tickets += sales.tickets
+		total.cancelled += sales.cancelled
+		total.revenue += sales.revenue
+		total.refunds += sales.refunds
+	}
+	fmt.Fprintf(table, "Total\t%v\t%v\t%v\t%v\t%v\t\n", total.bookings, total.tickets, total.cancelled, formatPrice(total.revenue), formatPrice(total.refunds))
+	table.Flush()
+
+	fmt.Fprintln(w, "\nTickets by email domain")
+	domains := make([]string, 0, len(r.domainTickets))
+	for domain, tickets := range r.domainTickets {
+		if tickets > 0 {
+			domains = append(domains, domain)
+		}
+	}
+	//most tickets first
+	sort.Slice(domains, func(i, j int) bool {
+		if r.domainTickets[domains[i]] != r.domainTickets[domains[j]] {
+			return r.domainTickets[domains[i]] > r.domainTickets[domains[j]]
+		}
+		return domains[i] < domains[j]
+	})
+	for _, domain := range domains {
+		fmt.Fprintf(table, "%v\t%v\t\n", domain, r.domainTickets[domain])
+	}
+	table.Flush()
+
+	fmt.Fprintln(w, "\nSell-through")
+	for _, conference := range conferences {
+		sold := r.soldTickets[conference.name]
+		fmt.Fprintf(table, "%v\t%v of %v sold\t%.1f%%\t\n", conference.name, sold, conference.tickets, 100*float64(sold)/float64(conference.tickets))
+	}
+	table.Flush()
+
+	fmt.Fprintln(w, "\nDeliveries")
+	fmt.Fprintf(w, "%v sent, %v failed attempts, %v given up\n", r.sent, r.failedAttempts, len(r.gaveUp))
+	for _, event := range r.gaveUp {
+		fmt.Fprintf(w, "  %v %v to %v: %v\n", event.At.Local().Format("2006-01-02 15:04"), event.Reference, event.Email, event.Error)
+	}
+	if r.skipped > 0 {
+		fmt.Fprintf(w, "\n%v lines of the audit log could not be read and were left out\n", r.skipped)
+	}
+}
+
+// printReport reads the audit log at path and prints the sales report
+func printReport(path string) {
+	events, skipped, err := readAuditLog(path)
+	if err != nil {
+		fmt.Printf("Could not read the audit log: %v\n", err)
+		return
+	}
+	report := buildReport(events)
+	report.skipped = skipped
+	report.print(os.Stdout)
+}
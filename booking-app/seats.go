@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const seatMapPath = "seatmap.json"
+
+var (
+	ErrNoSeatMap      = errors.New("this conference has no numbered seats")
+	ErrUnknownSeat    = errors.New("there is no such seat")
+	ErrSeatTaken      = errors.New("that seat is already taken")
+	ErrWrongSeatCount = errors.New("pick one seat for every ticket")
+	ErrNoSeatsLeft    = errors.New("not enough seats left")
+)
+
+// Seat is one numbered seat, row names are unique across the whole venue so a label like C12 is enough to find it
+type Seat struct {
+	section    string
+	row        string
+	number     int
+	accessible bool
+}
+
+func (s Seat) label() string {
+	return fmt.Sprintf("%v%v", s.row, s.number)
+}
+
+// SeatMap is the seating of a venue, sections and their rows are listed from the best to the worst
+type SeatMap struct {
+	sections []seatSection
+}
+
+type seatSection struct {
+	name string
+	rows []seatRow
+}
+
+type seatRow struct {
+	name  string
+	seats []Seat //in order from one side to the other, seats next to each other here are next to each other in the room
+}
+
+// seatMapConfig is how a seat map is written in the seat map file, keyed by conference name:
+//
+//	{"Go Conference": {"sections": [{"name": "Front", "rows": [{"row": "A", "seats": 10, "accessible": [1, 10]}]}]}}
+type seatMapConfig struct {
+	Sections []struct {
+		Name string `json:"name"`
+		Rows []struct {
+			Row        string `json:"row"`
+			Seats      int    `json:"seats"`
+			Accessible []int  `json:"accessible"` //seat numbers with wheelchair space
+		} `json:"rows"`
+	} `json:"sections"`
+}
+
+// loadSeatMaps reads the seat map file and gives every conference in it numbered seats.
+// Conferences that aren't in the file, or every conference if there is no file, sell general admission.
+func loadSeatMaps(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var configs map[string]seatMapConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+	for name, config := range configs {
+		conference := findConference(name)
+		if conference == nil {
+			return fmt.Errorf("%v has a seat map for %q which is not a known conference", path, name)
+		}
+		seatMap, err := newSeatMap(config)
+		if err != nil {
+			return fmt.Errorf("%v: seat map for %v: %w", path, name, err)
+		}
+		if seats := seatMap.count(); uint(seats) < conference.tickets {
+			return fmt.Errorf("%v: seat map for %v has %v seats but the conference sells %v tickets", path, name, seats, conference.tickets)
+		}
+		conference.seatMap = seatMap
+	}
+	return nil
+}
+
+func newSeatMap(config seatMapConfig) (*SeatMap, error) {
+	seatMap := &SeatMap{}
+	rowNames := make(map[string]bool)
+	for _, sectionConfig := range config.Sections {
+		section := seatSection{name: sectionConfig.Name}
+		for _, rowConfig := range sectionConfig.Rows {
+			name := strings.ToUpper(strings.TrimSpace(rowConfig.Row))
+			if name == "" || rowConfig.Seats <= 0 {
+				return nil, fmt.Errorf("every row in %v needs a name and at least one seat", section.name)
+			}
+			if rowNames[name] {
+				return nil, fmt.Errorf("row %v is listed twice", name)
+			}
+			//seat labels are the row and the number, so row A1 seat 1 would be A11 just like row A seat 11
+			if last := name[len(name)-1]; last >= '0' && last <= '9' {
+				return nil, fmt.Errorf("row %v can't end in a number, its seats would be mixed up with another row's", name)
+			}
+			rowNames[name] = true
+			row := seatRow{name: name}
+			for number := 1; number <= rowConfig.Seats; number++ {
+				row.seats = append(row.seats, Seat{section: section.name, row: name, number: number})
+			}
+			for _, number := range rowConfig.Accessible {
+				if number < 1 || number > rowConfig.Seats {
+					return nil, fmt.Errorf("row %v has no seat %v to make accessible", name, number)
+				}
+				row.seats[number-1].accessible = true
+			}
+			section.rows = append(section.rows, row)
+		}
+		seatMap.sections = append(seatMap.sections, section)
+	}
+	return seatMap, nil
+}
+
+func (m *SeatMap) count() int {
+	seats := 0
+	for _, section := range m.sections {
+		for _, row := range section.rows {
+			seats += len(row.seats)
+		}
+	}
+	return seats
+}
+
+// find looks a seat up by its label, any case
+func (m *SeatMap) find(label string) (Seat, bool) {
+	label = strings.ToUpper(strings.TrimSpace(label))
+	for _, section := range m.sections {
+		for _, row := range section.rows {
+			for _, seat := range row.seats {
+				if seat.label() == label {
+					return seat, true
+				}
+			}
+		}
+	}
+	return Seat{}, false
+}
+
+// assignSeats checks the seats a booking asked for, or picks the best ones left if it didn't ask for any.
+// The caller holds c.mu and calls takeSeats once the booking is saved.
+func (c *Conference) assignSeats(booking *UserData) error {
+	if c.seatMap == nil {
+		if len(booking.seats) > 0 {
+			return ErrNoSeatMap
+		}
+		return nil
+	}
+	if len(booking.seats) == 0 {
+		seats := c.bestSeats(int(booking.numberOfTickets), booking.accessible)
+		if seats == nil {
+			return ErrNoSeatsLeft
+		}
+		booking.seats = seats
+		return nil
+	}
+
+	if uint(len(booking.seats)) != booking.numberOfTickets {
+		return ErrWrongSeatCount
+	}
+	picked := make([]string, 0, len(booking.seats))
+	for _, label := range booking.seats {
+		seat, ok := c.seatMap.find(label)
+		if !ok {
+			return fmt.Errorf("%w: %v", ErrUnknownSeat, label)
+		}
+		if _, taken := c.seatsTaken[seat.label()]; taken || indexOfSeat(picked, seat.label()) >= 0 {
+			return fmt.Errorf("%w: %v", ErrSeatTaken, seat.label())
+		}
+		picked = append(picked, seat.label())
+	}
+	booking.seats = picked
+	return nil
+}
+
+// takeSeats marks a booking's seats as taken, the caller holds c.mu
+func (c *Conference) takeSeats(booking UserData) {
+	for _, label := range booking.seats {
+		c.seatsTaken[label] = booking.reference
+	}
+}
+
+// freeSeats gives back the seats of the last n tickets still on a booking, cancellations always take tickets off the end.
+// The caller holds c.mu and calls it before the cancellation is applied.
+func (c *Conference) freeSeats(booking UserData, n uint) {
+	active := booking.activeTickets()
+	if uint(len(booking.seats)) < active || n > active {
+		return //booked before the conference had a seat map
+	}
+	for _, label := range booking.seats[active-n : active] {
+		delete(c.seatsTaken, label)
+	}
+}
+
+// activeSeats are the seats of the tickets that haven't been cancelled
+func (u UserData) activeSeats() []string {
+	if active := u.activeTickets(); uint(len(u.seats)) >= active {
+		return u.seats[:active]
+	}
+	return nil
+}
+
+// bestSeats picks n free seats, the caller holds c.mu. It looks for n seats next to each other in the best row,
+// as close to the middle as it can, and only splits a group up when no row has room for all of them.
+// Accessible seats are kept for the people who ask for them until there is nothing else left.
+// It returns nil if there aren't n seats left.
+func (c *Conference) bestSeats(n int, accessible bool) []string {
+	isFree := func(seat Seat) bool {
+		_, taken := c.seatsTaken[seat.label()]
+		return !taken
+	}
+	if accessible {
+		return c.pickSeats(n, func(seat Seat) bool { return isFree(seat) && seat.accessible })
+	}
+	if seats := c.pickSeats(n, func(seat Seat) bool { return isFree(seat) && !seat.accessible }); seats != nil {
+		return seats
+	}
+	return c.pickSeats(n, isFree)
+}
+
+func (c *Conference) pickSeats(n int, allowed func(Seat) bool) []string {
+	if n <= 0 {
+		return nil
+	}
+	//a block of n seats next to each other, in the first row that has one
+	for _, section := range c.seatMap.sections {
+		for _, row := range section.rows {
+			best := -1
+			for start := 0; start+n <= len(row.seats); start++ {
+				if !allAllowed(row.seats[start:start+n], allowed) {
+					continue
+				}
+				if best < 0 || distanceFromMiddle(start, n, len(row.seats)) < distanceFromMiddle(best, n, len(row.seats)) {
+					best = start
+				}
+			}
+			if best >= 0 {
+				return seatLabels(row.seats[best : best+n])
+			}
+		}
+	}
+
+	//no row has room for everyone together, take the best single seats
+	var free []Seat
+	rank := make(map[string]int)
+	for _, section := range c.seatMap.sections {
+		for _, row := range section.rows {
+			rowStart := len(free)
+			for i, seat := range row.seats {
+				if allowed(seat) {
+					free = append(free, seat)
+					rank[seat.label()] = distanceFromMiddle(i, 1, len(row.seats))
+				}
+			}
+			rowSeats := free[rowStart:]
+			sort.SliceStable(rowSeats, func(i, j int) bool { return rank[rowSeats[i].label()] < rank[rowSeats[j].label()] })
+		}
+	}
+	if len(free) < n {
+		return nil
+	}
+	return seatLabels(free[:n])
+}
+
+// distanceFromMiddle is how far a block of n seats starting at start is from the middle of a row, doubled to stay whole
+func distanceFromMiddle(start int, n int, rowLength int) int {
+	distance := 2*start + n - rowLength
+	if distance < 0 {
+		return -distance
+	}
+	return distance
+}
+
+func allAllowed(seats []Seat, allowed func(Seat) bool) bool {
+	for _, seat := range seats {
+		if !allowed(seat) {
+			return false
+		}
+	}
+	return true
+}
+
+func seatLabels(seats []Seat) []string {
+	labels := make([]string, 0, len(seats))
+	for _, seat := range seats {
+		labels = append(labels, seat.label())
+	}
+	return labels
+}
+
+func indexOfSeat(labels []string, label string) int {
+	for i, l := range labels {
+		if l == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseSeats splits a list of seats like "A5,A6" or "A5 A6"
+func parseSeats(list string) []string {
+	return strings.FieldsFunc(strings.ToUpper(list), func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// renderSeatMap draws the seat map in the terminal, o is a free seat, a a free accessible seat and x a taken one
+func renderSeatMap(w io.Writer, c *Conference) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seatMap == nil {
+		return
+	}
+
+	widest := 0
+	for _, section := range c.seatMap.sections {
+		for _, row := range section.rows {
+			if len(row.seats) > widest {
+				widest = len(row.seats)
+			}
+		}
+	}
+	indent := 5 + widest - len("STAGE")/2
+	if indent < 0 {
+		indent = 0
+	}
+	fmt.Fprintf(w, "%vSTAGE\n", strings.Repeat(" ", indent))
+	for _, section := range c.seatMap.sections {
+		fmt.Fprintln(w, section.name)
+		for _, row := range section.rows {
+			//center shorter rows under the wider ones
+			fmt.Fprintf(w, "%3v  %v", row.name, strings.Repeat(" ", widest-len(row.seats)))
+			for _, seat := range row.seats {
+				mark := "o"
+				if _, taken := c.seatsTaken[seat.label()]; taken {
+					mark = "x"
+				} else if seat.accessible {
+					mark = "a"
+				}
+				fmt.Fprintf(w, "%v ", mark)
+			}
+			fmt.Fprintf(w, " 1-%v\n", len(row.seats))
+		}
+	}
+	fmt.Fprintln(w, "o free  a accessible  x taken")
+}
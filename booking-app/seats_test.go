@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewSeatMapRejectsRowsEndingInANumber(t *testing.T) {
+	tests := []struct {
+		name  string
+		rows  string
+		valid bool
+	}{
+		{"letters", `[{"row": "A", "seats": 11}, {"row": "AA", "seats": 1}]`, true},
+		{"number in the middle", `[{"row": "A1B", "seats": 2}]`, true},
+		{"ends in a number", `[{"row": "A", "seats": 11}, {"row": "A1", "seats": 1}]`, false},
+		{"only a number", `[{"row": "7", "seats": 3}]`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config seatMapConfig
+			if err := json.Unmarshal([]byte(`{"sections": [{"name": "Floor", "rows": `+test.rows+`}]}`), &config); err != nil {
+				t.Fatal(err)
+			}
+			_, err := newSeatMap(config)
+			if test.valid && err != nil {
+				t.Errorf("rejected a seat map with good row names: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("accepted a row name ending in a number")
+			}
+		})
+	}
+}
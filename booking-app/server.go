@@ -0,0 +1,612 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bookingRequest is the body of POST /bookings
+type bookingRequest struct {
+	Conference string   `json:"conference"`
+	FirstName  string   `json:"firstName"`
+	LastName   string   `json:"lastName"`
+	Email      string   `json:"email"`
+	Tickets    uint     `json:"tickets"`
+	Tier       string   `json:"tier"`
+	Discount   string   `json:"discountCode,omitempty"`
+	Seats      []string `json:"seats,omitempty"`      //like ["C5", "C6"], the best seats left are picked if empty
+	Accessible bool     `json:"accessible,omitempty"` //pick accessible seats
+}
+
+// bookingResponse is how a booking is shown over the API, the reference and email are left out of public lists
+type bookingResponse struct {
+	Reference  string   `json:"reference,omitempty"`
+	Conference string   `json:"conference"`
+	FirstName  string   `json:"firstName"`
+	LastName   string   `json:"lastName"`
+	Email      string   `json:"email,omitempty"`
+	Tickets    uint     `json:"tickets"`
+	Tier       string   `json:"tier"`
+	UnitPrice  int64    `json:"unitPrice"` //in cents
+	Discount   int64    `json:"discount"`
+	Code       string   `json:"discountCode,omitempty"`
+	Total      int64    `json:"total"`
+	Status     string   `json:"status"`
+	Cancelled  uint     `json:"cancelledTickets"`
+	Refunded   int64    `json:"refunded"`
+	Seats      []string `json:"seats,omitempty"` //the seats of the tickets that haven't been cancelled
+}
+
+// cancelResponse is the booking after a cancellation and what this cancellation refunded
+type cancelResponse struct {
+	Booking   bookingResponse `json:"booking"`
+	Cancelled uint            `json:"cancelledTickets"`
+	Refund    int64           `json:"refund"` //in cents
+}
+
+// reviewResponse is a booking waiting for review and the booking it looks like a duplicate of
+type reviewResponse struct {
+	Booking     bookingResponse `json:"booking"`
+	DuplicateOf string          `json:"duplicateOf"`
+}
+
+// holdResponse is a hold over the API, the booking in it has no reference until it is confirmed
+type holdResponse struct {
+	Reference string          `json:"reference"`
+	ExpiresAt time.Time       `json:"expiresAt"`
+	Booking   bookingResponse `json:"booking"`
+}
+
+// capacityResponse is how many tickets a conference has and how many are left
+type capacityResponse struct {
+	Conference string `json:"conference"`
+	Tickets    uint   `json:"tickets"`
+	Remaining  uint   `json:"remaining"`
+}
+
+// waitlistResponse is how a waitlist entry is shown over the API, the email is left out of the public list
+type waitlistResponse struct {
+	Reference  string `json:"reference"`
+	Conference string `json:"conference"`
+	Position   int    `json:"position"`
+	FirstName  string `json:"firstName"`
+	LastName   string `json:"lastName"`
+	Email      string `json:"email,omitempty"`
+	Tickets    uint   `json:"tickets"`
+}
+
+// seatMapResponse is a conference's seat map with which seats are taken
+type seatMapResponse struct {
+	Conference string                `json:"conference"`
+	Sections   []seatSectionResponse `json:"sections"`
+}
+
+type seatSectionResponse struct {
+	Name string            `json:"name"`
+	Rows []seatRowResponse `json:"rows"`
+}
+
+type seatRowResponse struct {
+	Row   string         `json:"row"`
+	Seats []seatResponse `json:"seats"`
+}
+
+type seatResponse struct {
+	Seat       string `json:"seat"`
+	Accessible bool   `json:"accessible,omitempty"`
+	Taken      bool   `json:"taken"`
+}
+
+// checkInRequest is the body of POST /checkin
+type checkInRequest struct {
+	Code string `json:"code"`
+}
+
+// checkInResponse is the ticket that was let in
+type checkInResponse struct {
+	TicketID    string    `json:"ticketId"`
+	Conference  string    `json:"conference"`
+	Reference   string    `json:"reference"`
+	Seat        uint      `json:"seat"`
+	FirstName   string    `json:"firstName"`
+	LastName    string    `json:"lastName"`
+	CheckedInAt time.Time `json:"checkedInAt"`
+}
+
+// errorResponse is sent for every request that fails, problems lists each validation failure
+type errorResponse struct {
+	Error    string           `json:"error"`
+	Problems ValidationErrors `json:"problems,omitempty"`
+}
+
+// newServer wires up the booking API
+//
+//	GET    /capacity[?conference=name]  tickets left for one or every conference
+//	GET    /bookings[?conference=name]  bookings for one or every conference, without references or emails
+//	POST   /bookings                    create a booking
+//	DELETE /bookings?reference=BK-...&email=...[&tickets=n]  cancel n or all of a booking's tickets
+//	POST   /holds                       hold tickets for a booking, same body as POST /bookings
+//	DELETE /holds?reference=HD-...      give held tickets back
+//	POST   /confirm?reference=HD-...    book held tickets before the hold expires
+//	GET    /waitlist?conference=name    the waitlist of a conference in order, without emails
+//	POST   /waitlist                    join the waitlist of a sold out conference
+//	POST   /checkin                     check in a signed ticket code at the door
+//	GET    /seats?conference=name       the seat map of a conference and which seats are taken
+//	GET    /reviews[?conference=name]   bookings that look like duplicates and wait for review
+//	POST   /reviews?reference=BK-...&decision=approve|reject  send the tickets or cancel with a full refund
+//
+// /reviews is for operators, it wants an Authorization: Bearer header with the OPERATOR_TOKEN the server runs with
+func newServer(delivery *ticketDelivery) http.Handler {
+	s := &bookingServer{delivery: delivery}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/capacity", s.handleCapacity)
+	mux.HandleFunc("/bookings", s.handleBookings)
+	mux.HandleFunc("/holds", s.handleHolds)
+	mux.HandleFunc("/confirm", s.handleConfirm)
+	mux.HandleFunc("/waitlist", s.handleWaitlist)
+	mux.HandleFunc("/checkin", s.handleCheckIn)
+	mux.HandleFunc("/seats", s.handleSeats)
+	mux.HandleFunc("/reviews", operatorOnly(s.handleReviews))
+	return mux
+}
+
+// operatorToken is what operatorOnly checks requests against, empty turns the operator endpoints off
+var operatorToken string
+
+// operatorOnly lets a request through to next only if it carries the operator token
+func operatorOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if operatorToken == "" {
+			writeError(w, http.StatusForbidden, "set OPERATOR_TOKEN on the server to use this over the API")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(operatorToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "this needs the operator token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// bookingServer handles the API requests, new bookings get their tickets sent through delivery
+type bookingServer struct {
+	delivery *ticketDelivery
+}
+
+func (s *bookingServer) handleCapacity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	selected, ok := selectConferences(w, r)
+	if !ok {
+		return
+	}
+	capacity := make([]capacityResponse, 0, len(selected))
+	for _, conference := range selected {
+		remaining, _ := conference.snapshot()
+		capacity = append(capacity, capacityResponse{Conference: conference.name, Tickets: conference.tickets, Remaining: remaining})
+	}
+	writeJSON(w, http.StatusOK, capacity)
+}
+
+func (s *bookingServer) handleBookings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listBookings(w, r)
+	case http.MethodPost:
+		s.createBooking(w, r)
+	case http.MethodDelete:
+		s.cancelBooking(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *bookingServer) listBookings(w http.ResponseWriter, r *http.Request) {
+	selected, ok := selectConferences(w, r)
+	if !ok {
+		return
+	}
+	list := make([]bookingResponse, 0)
+	for _, conference := range selected {
+		_, bookings := conference.snapshot()
+		for _, booking := range bookings {
+			//the reference and email are all DELETE /bookings asks for, so anyone could cancel from this list
+			response := toBookingResponse(conference, booking)
+			response.Reference, response.Email = "", ""
+			list = append(list, response)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *bookingServer) createBooking(w http.ResponseWriter, r *http.Request) {
+	conference, userData, ok := decodeBooking(w, r)
+	if !ok {
+		return
+	}
+	//another request can take the tickets after decodeBooking checked them, book checks again under the lock
+	booking, err := conference.book(userData)
+	if writeBookingError(w, err) {
+		return
+	}
+	writeJSON(w, s.bookedStatus(conference, booking), toBookingResponse(conference, booking))
+}
+
+// decodeBooking reads and validates a bookingRequest, it writes the error response itself if it returns false
+func decodeBooking(w http.ResponseWriter, r *http.Request) (*Conference, UserData, bool) {
+	var req bookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "request body is not valid JSON: "+err.Error())
+		return nil, UserData{}, false
+	}
+	conference := findConference(req.Conference)
+	if conference == nil {
+		writeError(w, http.StatusNotFound, "unknown conference")
+		return nil, UserData{}, false
+	}
+
+	remaining, _ := conference.snapshot()
+	if problems := ValidateUserInput(req.FirstName, req.LastName, req.Email, req.Tickets, remaining); len(problems) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "invalid booking", Problems: problems})
+		return nil, UserData{}, false
+	}
+	return conference, UserData{
+		firstName:       req.FirstName,
+		lastName:        req.LastName,
+		email:           req.Email,
+		numberOfTickets: req.Tickets,
+		tier:            req.Tier,
+		discountCode:    req.Discount,
+		seats:           req.Seats,
+		accessible:      req.Accessible,
+	}, true
+}
+
+// writeBookingError sends the response for an error from booking, holding or confirming tickets, false if there was no error
+func writeBookingError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNotEnoughTickets), errors.Is(err, ErrTierSoldOut), errors.Is(err, ErrDiscountUsedUp),
+		errors.Is(err, ErrSeatTaken), errors.Is(err, ErrNoSeatsLeft), errors.Is(err, ErrCustomerLimit), errors.Is(err, ErrNotInReview):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrUnknownTier), errors.Is(err, ErrUnknownDiscount), errors.Is(err, ErrDiscountExpired),
+		errors.Is(err, ErrUnknownSeat), errors.Is(err, ErrWrongSeatCount), errors.Is(err, ErrNoSeatMap):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, ErrHoldNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrHoldExpired):
+		writeError(w, http.StatusGone, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+	return true
+}
+
+func (s *bookingServer) handleHolds(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		conference, userData, ok := decodeBooking(w, r)
+		if !ok {
+			return
+		}
+		hold, err := conference.hold(userData)
+		if writeBookingError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusCreated, toHoldResponse(conference, hold))
+	case http.MethodDelete:
+		reference := r.URL.Query().Get("reference")
+		conference := findHold(reference)
+		if conference == nil {
+			writeError(w, http.StatusNotFound, ErrHoldNotFound.Error())
+			return
+		}
+		if writeBookingError(w, conference.release(reference)) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *bookingServer) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	reference := r.URL.Query().Get("reference")
+	conference := findHold(reference)
+	if conference == nil {
+		writeError(w, http.StatusNotFound, ErrHoldNotFound.Error())
+		return
+	}
+	booking, err := conference.confirm(reference)
+	if writeBookingError(w, err) {
+		return
+	}
+	writeJSON(w, s.bookedStatus(conference, booking), toBookingResponse(conference, booking))
+}
+
+func (s *bookingServer) cancelBooking(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	reference := query.Get("reference")
+	var tickets uint64 //0 cancels every ticket left on the booking
+	if query.Get("tickets") != "" {
+		var err error
+		tickets, err = strconv.ParseUint(query.Get("tickets"), 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "tickets must be a whole number")
+			return
+		}
+	}
+	conference := findBooking(reference)
+	if conference == nil {
+		writeError(w, http.StatusNotFound, ErrBookingNotFound.Error())
+		return
+	}
+	result, err := conference.cancel(reference, query.Get("email"), uint(tickets))
+	switch {
+	case errors.Is(err, ErrBookingNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrAlreadyCancelled), errors.Is(err, ErrTooManyToCancel):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		//the freed tickets may have gone to people on the waitlist, they get their tickets the same way
+		for _, waitlisted := range result.promoted {
+			s.delivery.enqueue(newTicketJob(conference.name, waitlisted))
+		}
+		writeJSON(w, http.StatusOK, cancelResponse{
+			Booking:   toBookingResponse(conference, result.booking),
+			Cancelled: result.tickets,
+			Refund:    result.refund,
+		})
+	}
+}
+
+// bookedStatus sends the tickets of a new booking and picks its response status,
+// 202 for a booking that was taken but waits for review before it is confirmed
+func (s *bookingServer) bookedStatus(conference *Conference, booking UserData) int {
+	if !s.delivery.enqueueBooking(conference.name, booking) {
+		return http.StatusAccepted
+	}
+	return http.StatusCreated
+}
+
+func (s *bookingServer) handleReviews(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		selected, ok := selectConferences(w, r)
+		if !ok {
+			return
+		}
+		list := make([]reviewResponse, 0)
+		for _, conference := range selected {
+			for _, booking := range conference.inReview() {
+				list = append(list, reviewResponse{Booking: toBookingResponse(conference, booking), DuplicateOf: booking.duplicateOf})
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		reference := r.URL.Query().Get("reference")
+		conference := findBooking(reference)
+		if conference == nil {
+			writeError(w, http.StatusNotFound, ErrBookingNotFound.Error())
+			return
+		}
+		switch r.URL.Query().Get("decision") {
+		case "approve":
+			booking, err := conference.approve(reference)
+			if writeBookingError(w, err) {
+				return
+			}
+			s.delivery.enqueue(newTicketJob(conference.name, booking))
+			writeJSON(w, http.StatusOK, toBookingResponse(conference, booking))
+		case "reject":
+			result, err := conference.reject(reference)
+			if writeBookingError(w, err) {
+				return
+			}
+			for _, waitlisted := range result.promoted {
+				s.delivery.enqueue(newTicketJob(conference.name, waitlisted))
+			}
+			writeJSON(w, http.StatusOK, cancelResponse{
+				Booking:   toBookingResponse(conference, result.booking),
+				Cancelled: result.tickets,
+				Refund:    result.refund,
+			})
+		default:
+			writeError(w, http.StatusBadRequest, "decision must be approve or reject")
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *bookingServer) handleWaitlist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		conference := findConference(r.URL.Query().Get("conference"))
+		if conference == nil {
+			writeError(w, http.StatusNotFound, "unknown conference")
+			return
+		}
+		list := make([]waitlistResponse, 0)
+		for i, entry := range conference.waitlistSnapshot() {
+			response := toWaitlistResponse(conference, entry, i+1)
+			response.Email = ""
+			list = append(list, response)
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		s.joinWaitlist(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *bookingServer) joinWaitlist(w http.ResponseWriter, r *http.Request) {
+	var req bookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "request body is not valid JSON: "+err.Error())
+		return
+	}
+	conference := findConference(req.Conference)
+	if conference == nil {
+		writeError(w, http.StatusNotFound, "unknown conference")
+		return
+	}
+	//nobody can wait for more tickets than the conference has in total
+	if problems := ValidateUserInput(req.FirstName, req.LastName, req.Email, req.Tickets, conference.tickets); len(problems) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "invalid waitlist request", Problems: problems})
+		return
+	}
+
+	entry, position, err := conference.joinWaitlist(waitlistEntry{firstName: req.FirstName, lastName: req.LastName, email: req.Email, numberOfTickets: req.Tickets})
+	switch {
+	case errors.Is(err, ErrNotSoldOut), errors.Is(err, ErrNotEnoughTickets), errors.Is(err, ErrCustomerLimit), errors.Is(err, ErrNotInReview):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, toWaitlistResponse(conference, entry, position))
+	}
+}
+
+func (s *bookingServer) handleCheckIn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req checkInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "request body is not valid JSON: "+err.Error())
+		return
+	}
+	checkedIn, err := checkIn(req.Code)
+	switch {
+	case errors.Is(err, ErrForgedTicket):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrTicketCancelled), errors.Is(err, ErrAlreadyCheckedIn), errors.Is(err, ErrTicketInReview):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, checkInResponse{
+			TicketID:    checkedIn.ticket.id(),
+			Conference:  checkedIn.conference.name,
+			Reference:   checkedIn.booking.reference,
+			Seat:        checkedIn.ticket.seat,
+			FirstName:   checkedIn.booking.firstName,
+			LastName:    checkedIn.booking.lastName,
+			CheckedInAt: checkedIn.at,
+		})
+	}
+}
+
+func (s *bookingServer) handleSeats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	conference := findConference(r.URL.Query().Get("conference"))
+	if conference == nil {
+		writeError(w, http.StatusNotFound, "unknown conference")
+		return
+	}
+	if conference.seatMap == nil {
+		writeError(w, http.StatusNotFound, ErrNoSeatMap.Error())
+		return
+	}
+
+	conference.mu.Lock()
+	defer conference.mu.Unlock()
+	response := seatMapResponse{Conference: conference.name, Sections: make([]seatSectionResponse, 0)}
+	for _, section := range conference.seatMap.sections {
+		sectionResponse := seatSectionResponse{Name: section.name, Rows: make([]seatRowResponse, 0)}
+		for _, row := range section.rows {
+			rowResponse := seatRowResponse{Row: row.name, Seats: make([]seatResponse, 0, len(row.seats))}
+			for _, seat := range row.seats {
+				_, taken := conference.seatsTaken[seat.label()]
+				rowResponse.Seats = append(rowResponse.Seats, seatResponse{Seat: seat.label(), Accessible: seat.accessible, Taken: taken})
+			}
+			sectionResponse.Rows = append(sectionResponse.Rows, rowResponse)
+		}
+		response.Sections = append(response.Sections, sectionResponse)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// selectConferences returns the conference named in the query, or every conference if none is named
+func selectConferences(w http.ResponseWriter, r *http.Request) ([]*Conference, bool) {
+	name := r.URL.Query().Get("conference")
+	if name == "" {
+		return conferences, true
+	}
+	conference := findConference(name)
+	if conference == nil {
+		writeError(w, http.StatusNotFound, "unknown conference")
+		return nil, false
+	}
+	return []*Conference{conference}, true
+}
+
+func toBookingResponse(conference *Conference, booking UserData) bookingResponse {
+	return bookingResponse{
+		Reference:  booking.reference,
+		Conference: conference.name,
+		FirstName:  booking.firstName,
+		LastName:   booking.lastName,
+		Email:      booking.email,
+		Tickets:    booking.numberOfTickets,
+		Tier:       booking.tier,
+		UnitPrice:  booking.unitPrice,
+		Discount:   booking.discount,
+		Code:       booking.discountCode,
+		Total:      booking.total,
+		Status:     booking.status,
+		Cancelled:  booking.cancelledTickets,
+		Refunded:   booking.refunded,
+		Seats:      booking.activeSeats(),
+	}
+}
+
+func toHoldResponse(conference *Conference, hold Hold) holdResponse {
+	booking := hold.booking
+	booking.status = bookingHeld
+	return holdResponse{Reference: hold.reference, ExpiresAt: hold.expires, Booking: toBookingResponse(conference, booking)}
+}
+
+func toWaitlistResponse(conference *Conference, entry waitlistEntry, position int) waitlistResponse {
+	return waitlistResponse{
+		Reference:  entry.reference,
+		Conference: conference.name,
+		Position:   position,
+		FirstName:  entry.firstName,
+		LastName:   entry.lastName,
+		Email:      entry.email,
+		Tickets:    entry.numberOfTickets,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
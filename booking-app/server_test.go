@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setUpConference points the package state at one general admission conference with a regular tier of quota tickets
+// and a ledger in a temp dir, so every test starts from an empty conference
+func setUpConference(t *testing.T, quota uint) *Conference {
+	t.Helper()
+	ledger, _, err := openLedger(filepath.Join(t.TempDir(), ledgerPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookingLedger = ledger
+	t.Cleanup(func() { ledger.close() })
+
+	start := time.Now().AddDate(0, 1, 0)
+	conference := newConference("Test Conference", start, start, newTier(tierRegular, 10000, quota))
+	conferences = []*Conference{conference}
+	ticketKey = []byte("test ticket key")
+	return conference
+}
+
+// discardTickets is a delivery that drops every ticket, it is waited for when the test ends
+func discardTickets(t *testing.T) *ticketDelivery {
+	delivery := startTicketDelivery(deliveryWorkers, func(ticketJob) error { return nil })
+	t.Cleanup(delivery.wait)
+	return delivery
+}
+
+func TestConcurrentBookingsNeverOversell(t *testing.T) {
+	const capacity = 50
+	const requests = 400
+	conference := setUpConference(t, capacity)
+	server := httptest.NewServer(newServer(discardTickets(t)))
+	defer server.Close()
+
+	//watch the capacity the whole time, remainingTickets is a uint so an oversell shows up as a huge number
+	stopWatching := make(chan struct{})
+	watched := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-stopWatching:
+				watched <- nil
+				return
+			default:
+			}
+			resp, err := http.Get(server.URL + "/capacity")
+			if err != nil {
+				watched <- err
+				return
+			}
+			var capacities []capacityResponse
+			err = json.NewDecoder(resp.Body).Decode(&capacities)
+			resp.Body.Close()
+			if err != nil {
+				watched <- err
+				return
+			}
+			if capacities[0].Remaining > capacity {
+				watched <- fmt.Errorf("remaining tickets wrapped around to %v", capacities[0].Remaining)
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	sold := uint(0)
+	statuses := make(map[int]int)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tickets := uint(i%3 + 1)
+			body, _ := json.Marshal(bookingRequest{
+				Conference: conference.name,
+				FirstName:  fmt.Sprintf("Buyer%v", i),
+				LastName:   "Test",
+				Email:      fmt.Sprintf("buyer%v@example.com", i),
+				Tickets:    tickets,
+				Tier:       tierRegular,
+			})
+			resp, err := http.Post(server.URL+"/bookings", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			status := resp.StatusCode
+			if status == http.StatusUnprocessableEntity {
+				//once the tickets look gone the request is turned away before it gets to book
+				var failed errorResponse
+				if err := json.NewDecoder(resp.Body).Decode(&failed); err == nil && len(failed.Problems) == 1 && failed.Problems[0].Code == codeNotAvailable {
+					status = http.StatusConflict
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			statuses[status]++
+			if status == http.StatusCreated || status == http.StatusAccepted {
+				sold += tickets
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(stopWatching)
+	if err := <-watched; err != nil {
+		t.Fatal(err)
+	}
+
+	for status, count := range statuses {
+		switch status {
+		case http.StatusCreated, http.StatusAccepted, http.StatusConflict:
+		default:
+			t.Errorf("%v requests got status %v", count, status)
+		}
+	}
+	if sold > capacity {
+		t.Fatalf("sold %v tickets, the conference only has %v", sold, capacity)
+	}
+	remaining, bookings := conference.snapshot()
+	if remaining+sold != capacity {
+		t.Errorf("%v tickets sold and %v remaining, want them to add up to %v", sold, remaining, capacity)
+	}
+	var booked uint
+	for _, booking := range bookings {
+		booked += booking.activeTickets()
+	}
+	if booked != sold {
+		t.Errorf("the conference has %v tickets booked, the responses say %v", booked, sold)
+	}
+}
+
+func TestWaitlistOverCustomerLimitIsConflict(t *testing.T) {
+	conference := setUpConference(t, 4)
+	previous := maxTicketsPerCustomer
+	maxTicketsPerCustomer = 4
+	t.Cleanup(func() { maxTicketsPerCustomer = previous })
+	server := httptest.NewServer(newServer(discardTickets(t)))
+	defer server.Close()
+
+	//ann buys the whole conference, so the waitlist is open but she can't wait for more
+	if _, err := conference.book(testBooking("ann", 4)); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(bookingRequest{Conference: conference.name, FirstName: "ann", LastName: "Test", Email: "Ann+more@example.com", Tickets: 1})
+	resp, err := http.Post(server.URL+"/waitlist", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("joining the waitlist over the customer limit got %v, want 409", resp.StatusCode)
+	}
+}
+
+func TestReviewDecisionsNeedTheOperatorToken(t *testing.T) {
+	conference := setUpConference(t, 10)
+	server := httptest.NewServer(newServer(discardTickets(t)))
+	defer server.Close()
+	previous := operatorToken
+	t.Cleanup(func() { operatorToken = previous })
+
+	if _, err := conference.book(testBooking("ann", 1)); err != nil {
+		t.Fatal(err)
+	}
+	var inReview []UserData
+	for _, email := range []string{"a.n.n@example.com", "an.n@example.com"} {
+		duplicate := testBooking("ann", 2)
+		duplicate.email = email
+		booking, err := conference.book(duplicate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if booking.status != bookingInReview {
+			t.Fatalf("the duplicate booking is %v, want it in review", booking.status)
+		}
+		inReview = append(inReview, booking)
+	}
+
+	decide := func(token, decision, reference string) int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/reviews?decision="+decision+"&reference="+reference, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name     string
+		server   string //the token the server runs with
+		token    string
+		decision string
+		booking  UserData
+		want     int
+	}{
+		{"no operator token set", "", "", "approve", inReview[0], http.StatusForbidden},
+		{"no token sent", "s3cret", "", "approve", inReview[0], http.StatusUnauthorized},
+		{"wrong token", "s3cret", "guess", "approve", inReview[0], http.StatusUnauthorized},
+		{"approve", "s3cret", "s3cret", "approve", inReview[0], http.StatusOK},
+		{"approve twice", "s3cret", "s3cret", "approve", inReview[0], http.StatusConflict},
+		{"reject", "s3cret", "s3cret", "reject", inReview[1], http.StatusOK},
+	}
+	for _, test := range tests {
+		operatorToken = test.server
+		if got := decide(test.token, test.decision, test.booking.reference); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	//the decisions change the server's own bookings, not a copy of them
+	if len(conference.inReview()) != 0 {
+		t.Errorf("%v bookings still in review", len(conference.inReview()))
+	}
+	remaining, bookings := conference.snapshot()
+	if remaining != 7 {
+		t.Errorf("%v tickets left, want the 2 rejected ones back on sale for 7", remaining)
+	}
+	for _, booking := range bookings {
+		if booking.reference == inReview[0].reference && booking.status != bookingConfirmed {
+			t.Errorf("the approved booking is %v", booking.status)
+		}
+	}
+	if _, err := conference.cancel(inReview[1].reference, inReview[1].email, 0); err == nil {
+		t.Error("a rejected booking could be cancelled again")
+	}
+}
+
+func TestPublicListsHideWhatCancelsABooking(t *testing.T) {
+	conference := setUpConference(t, 2)
+	server := httptest.NewServer(newServer(discardTickets(t)))
+	defer server.Close()
+
+	booking, err := conference.book(testBooking("ann", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conference.joinWaitlist(waitlistEntry{firstName: "bob", lastName: "Test", email: "bob@example.com", numberOfTickets: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/bookings", "/waitlist?conference=Test+Conference"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %v got %v", path, resp.StatusCode)
+		}
+		for _, secret := range []string{booking.reference, "ann@example.com", "bob@example.com"} {
+			if bytes.Contains(body.Bytes(), []byte(secret)) {
+				t.Errorf("GET %v shows %v to anyone: %v", path, secret, body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// session is one run of the booking prompts. It reads answers a line at a time from in and writes everything to out,
+// so the prompts can be driven by a person at a terminal, a replayed script or anything else with a Reader and a Writer.
+type session struct {
+	in     *bufio.Scanner
+	out    io.Writer
+	script bool //answers come from a script: skip # comment lines and echo every answer so the output reads like a transcript
+	done   bool //in ran out, every ask from now on gets an empty answer
+}
+
+func newSession(in io.Reader, out io.Writer) *session {
+	return &session{in: bufio.NewScanner(in), out: out}
+}
+
+// newScriptSession replays a recorded session file, one answer per line
+func newScriptSession(in io.Reader, out io.Writer) *session {
+	s := newSession(in, out)
+	s.script = true
+	return s
+}
+
+// ask writes the prompt and returns the next line, trimmed. It returns "" once the input has run out.
+func (s *session) ask(prompt string) string {
+	fmt.Fprint(s.out, prompt)
+	for !s.done {
+		if !s.in.Scan() {
+			s.done = true
+			fmt.Fprintln(s.out)
+			break
+		}
+		line := strings.TrimSpace(s.in.Text())
+		if s.script && strings.HasPrefix(line, "#") {
+			continue
+		}
+		if s.script {
+			fmt.Fprintln(s.out, line)
+		}
+		return line
+	}
+	return ""
+}
+
+// askNumber asks until it gets a whole number, anything else is pointed out and asked again.
+// It returns -1 once the input has run out.
+func (s *session) askNumber(prompt string) int {
+	for {
+		answer := s.ask(prompt)
+		if s.done {
+			return -1
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil {
+			return n
+		}
+		fmt.Fprintf(s.out, "Please enter a whole number, %q isn't one.\n", answer)
+	}
+}
+
+// askCount is askNumber for amounts that can't be negative
+func (s *session) askCount(prompt string) uint {
+	for {
+		n := s.askNumber(prompt)
+		if s.done {
+			return 0
+		}
+		if n >= 0 {
+			return uint(n)
+		}
+		fmt.Fprintln(s.out, "Please enter 0 or more.")
+	}
+}
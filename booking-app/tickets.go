@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ticketKeyPath = "ticket.key"
+
+var (
+	ErrForgedTicket     = errors.New("ticket code is not valid")
+	ErrTicketCancelled  = errors.New("ticket has been cancelled")
+	ErrAlreadyCheckedIn = errors.New("ticket has already been checked in")
+	ErrTicketInReview   = errors.New("ticket belongs to a booking that is waiting for review")
+)
+
+// ticketKey signs every ticket code, it is loaded once at startup
+var ticketKey []byte
+
+// ticketCode is what a signed ticket code says
+type ticketCode struct {
+	conference string
+	reference  string
+	seat       uint //1 to the number of tickets on the booking
+}
+
+// loadTicketKey reads the signing key from TICKET_SECRET or the key file, and makes a new key file the first time.
+// The key has to survive restarts or every ticket already sent would stop scanning.
+func loadTicketKey(path string) ([]byte, error) {
+	if secret := os.Getenv("TICKET_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return hex.DecodeString(strings.TrimSpace(string(data)))
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	//O_EXCL so two processes starting at once can't each write their own key
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// id is the ticket's unique ID, the booking reference plus the seat number
+func (t ticketCode) id() string {
+	return fmt.Sprintf("%v-%v", t.reference, t.seat)
+}
+
+// sign turns the ticket into a code like <payload>.<signature>, both base64
+func (t ticketCode) sign(key []byte) string {
+	payload := t.conference + "|" + t.reference + "|" + strconv.FormatUint(uint64(t.seat), 10)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseTicketCode checks the signature on a code and returns what it says
+func parseTicketCode(code string, key []byte) (ticketCode, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(strings.TrimSpace(code), ".")
+	if !ok {
+		return ticketCode{}, ErrForgedTicket
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return ticketCode{}, ErrForgedTicket
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return ticketCode{}, ErrForgedTicket
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ticketCode{}, ErrForgedTicket
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 3 {
+		return ticketCode{}, ErrForgedTicket
+	}
+	seat, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return ticketCode{}, ErrForgedTicket
+	}
+	return ticketCode{conference: fields[0], reference: fields[1], seat: uint(seat)}, nil
+}
+
+// ticketCodes signs a code for every ticket on a booking
+func ticketCodes(conferenceName string, booking UserData) []string {
+	codes := make([]string, 0, booking.numberOfTickets)
+	for seat := uint(1); seat <= booking.numberOfTickets; seat++ {
+		codes = append(codes, ticketCode{conference: conferenceName, reference: booking.reference, seat: seat}.sign(ticketKey))
+	}
+	return codes
+}
+
+// checkedInTicket is a ticket that was just let in at the door
+type checkedInTicket struct {
+	ticket     ticketCode
+	conference *Conference
+	booking    UserData
+	at         time.Time
+}
+
+// checkIn verifies a scanned code and records the check in, every ticket can only be used once
+func checkIn(code string) (checkedInTicket, error) {
+	ticket, err := parseTicketCode(code, ticketKey)
+	if err != nil {
+		return checkedInTicket{}, err
+	}
+	conference := findConference(ticket.conference)
+	if conference == nil {
+		return checkedInTicket{}, ErrForgedTicket
+	}
+
+	conference.mu.Lock()
+	defer conference.mu.Unlock()
+
+	i := conference.indexOf(ticket.reference)
+	if i < 0 {
+		return checkedInTicket{}, ErrForgedTicket
+	}
+	booking := conference.bookings[i]
+	//cancellations take tickets off the end of a booking, so the highest seats go first
+	if ticket.seat == 0 || ticket.seat > booking.activeTickets() {
+		return checkedInTicket{}, ErrTicketCancelled
+	}
+	if booking.status == bookingInReview {
+		return checkedInTicket{}, ErrTicketInReview
+	}
+	if _, used := conference.checkedIn[ticket.id()]; used {
+		return checkedInTicket{}, ErrAlreadyCheckedIn
+	}
+
+	now := clock.Now()
+	if err := bookingLedger.append(checkedInEntry(conference.name, ticket, now)); err != nil {
+		return checkedInTicket{}, err
+	}
+	conference.checkedIn[ticket.id()] = now
+	return checkedInTicket{ticket: ticket, conference: conference, booking: booking, at: now}, nil
+}
+
+// checkedInEntry is the ledger entry for a ticket being scanned at the door
+func checkedInEntry(conferenceName string, ticket ticketCode, at time.Time) ledgerEntry {
+	return ledgerEntry{
+		Type:       entryCheckedIn,
+		Reference:  ticket.reference,
+		Conference: conferenceName,
+		Seat:       ticket.seat,
+		At:         at.UTC(),
+	}
+}
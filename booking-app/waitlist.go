@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var ErrNotSoldOut = errors.New("tickets are still available, book them instead of joining the waitlist")
+
+// waitlistEntry is someone waiting for tickets to free up on a sold out conference
+type waitlistEntry struct {
+	reference       string
+	firstName       string
+	lastName        string
+	email           string
+	numberOfTickets uint
+}
+
+// newWaitlistReference makes a random waitlist reference like WL-3F9A1C2E
+func newWaitlistReference() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("WL-%X", b)
+}
+
+// joinWaitlist puts someone at the back of the queue, only once the conference is sold out.
+// It returns the entry with its reference and its position in the queue.
+func (c *Conference) joinWaitlist(entry waitlistEntry) (waitlistEntry, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.remainingTickets > 0 {
+		return waitlistEntry{}, 0, ErrNotSoldOut
+	}
+	if entry.numberOfTickets == 0 || entry.numberOfTickets > c.tickets {
+		return waitlistEntry{}, 0, ErrNotEnoughTickets
+	}
+	//what the customer already has plus everything they are waiting for has to stay under the limit
+	waiting := entry.numberOfTickets
+	for _, queued := range c.waitlist {
+		if normalizeEmail(queued.email) == normalizeEmail(entry.email) {
+			waiting += queued.numberOfTickets
+		}
+	}
+	if c.customerTickets(entry.email)+waiting > maxTicketsPerCustomer {
+		return waitlistEntry{}, 0, ErrCustomerLimit
+	}
+	entry.reference = newWaitlistReference()
+	if err := bookingLedger.append(waitlistedEntry(c.name, entry)); err != nil {
+		return waitlistEntry{}, 0, err
+	}
+	c.waitlist = append(c.waitlist, entry)
+	return entry, len(c.waitlist), nil
+}
+
+// waitlistSnapshot copies the queue so it can be read without holding the lock
+func (c *Conference) waitlistSnapshot() []waitlistEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]waitlistEntry(nil), c.waitlist...)
+}
+
+// promoteWaitlist books freed tickets for the people at the front of the queue, in the order they joined.
+// It stops at the first person whose whole request doesn't fit so nobody gets skipped. The caller holds c.mu.
+func (c *Conference) promoteWaitlist() []UserData {
+	promoted := make([]UserData, 0)
+	for len(c.waitlist) > 0 {
+		next := c.waitlist[0]
+		tier := c.tierWithRoom(next.numberOfTickets)
+		if tier == nil {
+			break
+		}
+		booking := UserData{
+			reference:       newReference(),
+			firstName:       next.firstName,
+			lastName:        next.lastName,
+			email:           next.email,
+			numberOfTickets: next.numberOfTickets,
+			tier:            tier.name,
+			unitPrice:       tier.price,
+			total:           tier.price * int64(next.numberOfTickets),
+			status:          bookingConfirmed,
+			waitlist:        next.reference,
+		}
+		if err := c.assignSeats(&booking); err != nil {
+			break //the seats left aren't enough for them, wait for more to free up
+		}
+		if err := bookingLedger.append(bookedEntry(c.name, booking)); err != nil {
+			//leave them on the waitlist, the next cancellation tries again. This can run in the background,
+			//so it goes in the audit log instead of the middle of someone's prompt
+			auditTrail.record(promoteFailedEvent(c.name, next, err))
+			break
+		}
+		c.remainingTickets -= booking.numberOfTickets
+		c.bookings = append(c.bookings, booking)
+		c.takeSeats(booking)
+		c.waitlist = c.waitlist[1:]
+		auditTrail.record(bookingCreatedEvent(c.name, booking))
+		promoted = append(promoted, booking)
+	}
+	return promoted
+}
+
+// tierWithRoom returns the first tier that has n tickets left, the caller holds c.mu
+func (c *Conference) tierWithRoom(n uint) *TicketTier {
+	for i := range c.tiers {
+		if c.tierRemaining(&c.tiers[i]) >= n {
+			return &c.tiers[i]
+		}
+	}
+	return nil
+}
+
+// removeFromWaitlist drops a queued entry by reference, used when restoring promotions from the ledger
+func (c *Conference) removeFromWaitlist(reference string) {
+	for i, entry := range c.waitlist {
+		if entry.reference == reference {
+			c.waitlist = append(c.waitlist[:i], c.waitlist[i+1:]...)
+			return
+		}
+	}
+}
+
+// waitlistedEntry is the ledger entry for joining a waitlist
+func waitlistedEntry(conferenceName string, entry waitlistEntry) ledgerEntry {
+	return ledgerEntry{
+		Type:            entryWaitlisted,
+		Reference:       entry.reference,
+		Conference:      conferenceName,
+		FirstName:       entry.firstName,
+		LastName:        entry.lastName,
+		Email:           entry.email,
+		NumberOfTickets: entry.numberOfTickets,
+		At:              clock.Now().UTC(),
+	}
+}
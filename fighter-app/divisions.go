@@ -0,0 +1,64 @@
+package main
+
+// fighters under this weight aren't taken, it is well below the lightest division
+const minWeight = 100
+
+// weightTolerance is how many pounds over a division's limit a fighter can be and still make it, set with -tolerance
+var weightTolerance = 1
+
+// Division is one weight class, a fighter belongs in the lightest one whose limit they make
+type Division struct {
+	name     string
+	gender   string //men or women
+	limit    int    //upper weight limit in pounds
+	fighters []FighterInfo
+}
+
+// divisions is every weight class, lightest first for each gender
+var divisions = []*Division{
+	{name: "Flyweight", gender: "men", limit: 125},
+	{name: "Bantamweight", gender: "men", limit: 135},
+	{name: "Featherweight", gender: "men", limit: 145},
+	{name: "Lightweight", gender: "men", limit: 155},
+	{name: "Welterweight", gender: "men", limit: 170},
+	{name: "Middleweight", gender: "men", limit: 185},
+	{name: "Light Heavyweight", gender: "men", limit: 205},
+	{name: "Heavyweight", gender: "men", limit: 265},
+	{name: "Strawweight", gender: "women", limit: 115},
+	{name: "Flyweight", gender: "women", limit: 125},
+	{name: "Bantamweight", gender: "women", limit: 135},
+	{name: "Featherweight", gender: "women", limit: 145},
+}
+
+// title is the division name with its gender, like Women's Bantamweight
+func (d *Division) title() string {
+	if d.gender == "women" {
+		return "Women's " + d.name
+	}
+	return "Men's " + d.name
+}
+
+// classify returns the lightest division of the gender whose limit the weight makes, nil if it is too heavy for all of them
+func classify(weight int, gender string) *Division {
+	var found *Division
+	for _, division := range divisions {
+		if division.gender != gender || weight > division.limit+weightTolerance {
+			continue
+		}
+		if found == nil || division.limit < found.limit {
+			found = division
+		}
+	}
+	return found
+}
+
+// weightRange is the lightest and heaviest weight a fighter of the gender can have
+func weightRange(gender string) (int, int) {
+	heaviest := minWeight
+	for _, division := range divisions {
+		if division.gender == gender && division.limit+weightTolerance > heaviest {
+			heaviest = division.limit + weightTolerance
+		}
+	}
+	return minWeight, heaviest
+}
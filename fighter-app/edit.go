@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fighterRef is where a fighter sits in the roster
+type fighterRef struct {
+	division *Division
+	index    int
+}
+
+func (ref fighterRef) fighter() FighterInfo {
+	return ref.division.fighters[ref.index]
+}
+
+// lastChange is the roster as it was before the last add, edit or delete, nil once it has been undone
+var lastChange *rosterSnapshot
+
+type rosterSnapshot struct {
+	description string
+	fighters    [][]FighterInfo //one slice per division, in the order of divisions
+}
+
+// remember keeps a copy of the roster so the change about to be made can be undone
+func remember(description string) {
+	snapshot := &rosterSnapshot{description: description}
+	for _, division := range divisions {
+		snapshot.fighters = append(snapshot.fighters, append([]FighterInfo(nil), division.fighters...))
+	}
+	lastChange = snapshot
+}
+
+func undoLastChange() {
+	if lastChange == nil {
+		fmt.Println("There is nothing to undo")
+		return
+	}
+	for i, division := range divisions {
+		division.fighters = lastChange.fighters[i]
+	}
+	fmt.Println("Undid", lastChange.description)
+	lastChange = nil
+}
+
+// findFighters returns every fighter whose first, last or full name is name, ignoring case
+func findFighters(name string) []fighterRef {
+	found := make([]fighterRef, 0)
+	for _, division := range divisions {
+		for i, fighter := range division.fighters {
+			fullName := fighter.firstName + " " + fighter.lastName
+			if strings.EqualFold(fighter.firstName, name) || strings.EqualFold(fighter.lastName, name) || strings.EqualFold(fullName, name) {
+				found = append(found, fighterRef{division: division, index: i})
+			}
+		}
+	}
+	return found
+}
+
+func printFound() {
+	var name string
+	fmt.Print("Please enter the first or last name to find: ")
+	fmt.Scan(&name)
+	found := findFighters(name)
+	if len(found) == 0 {
+		fmt.Println("No fighter is called", name)
+		return
+	}
+	for _, ref := range found {
+		fighter := ref.fighter()
+		fmt.Printf("%v %v, %v, %v lbs, %v, reach %v\n", fighter.firstName, fighter.lastName, ref.division.title(), fighter.weight, fighter.southpaw, fighter.reach)
+	}
+}
+
+// chooseFighter asks for a name, and which one if more than one fighter has it
+func chooseFighter() (fighterRef, bool) {
+	var name string
+	fmt.Print("Please enter the first or last name of the fighter: ")
+	fmt.Scan(&name)
+	found := findFighters(name)
+	switch len(found) {
+	case 0:
+		fmt.Println("No fighter is called", name)
+		return fighterRef{}, false
+	case 1:
+		return found[0], true
+	}
+	for i, ref := range found {
+		fighter := ref.fighter()
+		fmt.Printf("%v: %v %v, %v, %v lbs\n", i+1, fighter.firstName, fighter.lastName, ref.division.title(), fighter.weight)
+	}
+	var choice int
+	fmt.Print("Please enter the number of the fighter: ")
+	fmt.Scan(&choice)
+	if choice < 1 || choice > len(found) {
+		fmt.Printf("Error, please enter a number from 1 to %v\n", len(found))
+		return fighterRef{}, false
+	}
+	return found[choice-1], true
+}
+
+func editFighter() {
+	ref, ok := chooseFighter()
+	if !ok {
+		return
+	}
+	fighter := ref.fighter()
+
+	var field string
+	var value string
+	fmt.Print("Please enter the field to change (first, last, gender, weight, reach or southpaw): ")
+	fmt.Scan(&field)
+	fmt.Print("\nPlease enter the new value: ")
+	fmt.Scan(&value)
+
+	switch field {
+	case "first":
+		fighter.firstName = value
+	case "last":
+		fighter.lastName = value
+	case "gender":
+		fighter.gender = value
+	case "weight":
+		weight, err := strconv.Atoi(value)
+		if err != nil {
+			fmt.Println("Please enter the weight as a whole number")
+			return
+		}
+		fighter.weight = weight
+	case "reach":
+		reach, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			fmt.Println("Please enter the reach as a whole number")
+			return
+		}
+		fighter.reach = uint(reach)
+	case "southpaw":
+		fighter.southpaw = value
+	default:
+		fmt.Println("Error, please enter first, last, gender, weight, reach or southpaw")
+		return
+	}
+
+	isValidName, isValidGender, isValidWeight, isValidReach, isValidSouthPawInput := validateInput(fighter.firstName, fighter.lastName, fighter.gender, fighter.weight, fighter.reach, fighter.southpaw)
+	if !isValidName || !isValidGender || !isValidWeight || !isValidReach || !isValidSouthPawInput {
+		fmt.Printf("%v can't be %v, nothing was changed\n", field, value)
+		return
+	}
+
+	remember(fmt.Sprintf("the change to %v %v", fighter.firstName, fighter.lastName))
+	//a new weight or gender can mean another division
+	division := classify(fighter.weight, fighter.gender)
+	if division == ref.division {
+		ref.division.fighters[ref.index] = fighter
+		fmt.Printf("Changed %v %v\n", fighter.firstName, fighter.lastName)
+		return
+	}
+	removeFighter(ref)
+	division.fighters = append(division.fighters, fighter)
+	fmt.Printf("Changed %v %v, who moves to %v\n", fighter.firstName, fighter.lastName, division.title())
+}
+
+func deleteFighter() {
+	ref, ok := chooseFighter()
+	if !ok {
+		return
+	}
+	fighter := ref.fighter()
+
+	var answer string
+	fmt.Printf("Delete %v %v from %v? Type yes to confirm: ", fighter.firstName, fighter.lastName, ref.division.title())
+	fmt.Scan(&answer)
+	if !strings.EqualFold(answer, "yes") {
+		fmt.Println("Nothing was deleted")
+		return
+	}
+	remember(fmt.Sprintf("deleting %v %v", fighter.firstName, fighter.lastName))
+	removeFighter(ref)
+	fmt.Printf("Deleted %v %v\n", fighter.firstName, fighter.lastName)
+}
+
+// removeFighter takes a fighter out of their division, keeping the others in order
+func removeFighter(ref fighterRef) {
+	fighters := ref.division.fighters
+	ref.division.fighters = append(fighters[:ref.index:ref.index], fighters[ref.index+1:]...)
+}
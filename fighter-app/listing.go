@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// listing picks which fighters to show and in what order, the zero value is every fighter in the order they were entered
+type listing struct {
+	division *Division //nil for every division
+	stance   string    //southpaw or orthodox, "" for both
+	minReach uint      //0 for no lower limit
+	maxReach uint      //0 for no upper limit
+	name     string    //part of the first or last name, any case, "" for everyone
+	sortBy   string    //name, weight or reach, "" keeps the roster order
+}
+
+// listedFighter is a fighter with the division they are in
+type listedFighter struct {
+	FighterInfo
+	division *Division
+}
+
+// fighters returns the fighters that pass every filter, sorted. Names go A to Z by last name,
+// weight and reach go from the heaviest and longest down.
+func (l listing) fighters() []listedFighter {
+	name := strings.ToLower(l.name)
+	listed := make([]listedFighter, 0)
+	for _, division := range divisions {
+		if l.division != nil && division != l.division {
+			continue
+		}
+		for _, fighter := range division.fighters {
+			if l.stance != "" && fighter.southpaw != l.stance {
+				continue
+			}
+			if fighter.reach < l.minReach || (l.maxReach > 0 && fighter.reach > l.maxReach) {
+				continue
+			}
+			if name != "" && !strings.Contains(strings.ToLower(fighter.firstName+" "+fighter.lastName), name) {
+				continue
+			}
+			listed = append(listed, listedFighter{FighterInfo: fighter, division: division})
+		}
+	}
+
+	switch l.sortBy {
+	case "name":
+		sort.SliceStable(listed, func(i, j int) bool {
+			a, b := listed[i], listed[j]
+			if !strings.EqualFold(a.lastName, b.lastName) {
+				return strings.ToLower(a.lastName) < strings.ToLower(b.lastName)
+			}
+			return strings.ToLower(a.firstName) < strings.ToLower(b.firstName)
+		})
+	case "weight":
+		sort.SliceStable(listed, func(i, j int) bool { return listed[i].weight > listed[j].weight })
+	case "reach":
+		sort.SliceStable(listed, func(i, j int) bool { return listed[i].reach > listed[j].reach })
+	}
+	return listed
+}
+
+// renderTable writes the fighters as a table with aligned columns
+func renderTable(w io.Writer, fighters []listedFighter) {
+	if len(fighters) == 0 {
+		fmt.Fprintln(w, "No fighters match")
+		return
+	}
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "First Name\tLast Name\tDivision\tWeight\tReach\tStance")
+	for _, fighter := range fighters {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\t%v\n", fighter.firstName, fighter.lastName, fighter.division.title(), fighter.weight, fighter.reach, fighter.southpaw)
+	}
+	table.Flush()
+	fmt.Fprintf(w, "%v fighters\n", len(fighters))
+}
+
+func printListing(l listing) {
+	renderTable(os.Stdout, l.fighters())
+}
+
+// searchFighters asks for the filters and the order, "any" skips a filter
+func searchFighters() {
+	var l listing
+	var division int
+	var stance string
+	var name string
+	var sortBy string
+
+	for i, d := range divisions {
+		fmt.Printf("%v: %v\n", i+1, d.title())
+	}
+	fmt.Print("Please enter the number of the division, or 0 for all of them: ")
+	fmt.Scan(&division)
+	if division < 0 || division > len(divisions) {
+		fmt.Printf("Error, please enter a number from 0 to %v\n", len(divisions))
+		return
+	}
+	if division > 0 {
+		l.division = divisions[division-1]
+	}
+	fmt.Print("\nPlease enter southpaw, orthodox or any: ")
+	fmt.Scan(&stance)
+	if stance != "southpaw" && stance != "orthodox" && stance != "any" {
+		fmt.Println("Error, please enter southpaw, orthodox or any")
+		return
+	}
+	if stance != "any" {
+		l.stance = stance
+	}
+	fmt.Print("\nPlease enter the shortest reach, or 0 for no limit: ")
+	fmt.Scan(&l.minReach)
+	fmt.Print("\nPlease enter the longest reach, or 0 for no limit: ")
+	fmt.Scan(&l.maxReach)
+	fmt.Print("\nPlease enter part of a name to search for, or any: ")
+	fmt.Scan(&name)
+	if !strings.EqualFold(name, "any") {
+		l.name = name
+	}
+	fmt.Print("\nPlease enter how to sort, by name, weight, reach or none: ")
+	fmt.Scan(&sortBy)
+	switch sortBy {
+	case "name", "weight", "reach":
+		l.sortBy = sortBy
+	case "none":
+	default:
+		fmt.Println("Error, please enter name, weight, reach or none")
+		return
+	}
+	fmt.Println()
+	printListing(l)
+}
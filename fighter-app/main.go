@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 )
 
@@ -12,14 +13,10 @@ import (
 
 //var userOption ints
 
-var bantamWeight = make([]FighterInfo, 0)
-var featherWeight = make([]FighterInfo, 0)
-var lightWeight = make([]FighterInfo, 0)
-var welterWeight = make([]FighterInfo, 0)
-
 type FighterInfo struct {
 	firstName string
 	lastName  string
+	gender    string
 	weight    int
 	southpaw  string
 	reach     uint
@@ -27,35 +24,61 @@ type FighterInfo struct {
 
 func main() {
 
+	flag.IntVar(&weightTolerance, "tolerance", weightTolerance, "pounds a fighter can be over a division limit and still make it")
+	flag.StringVar(&rosterPath, "roster", rosterPath, "the file the roster is saved to and loaded from")
+	flag.Parse()
+
 	greetUsers()
+	//a roster that is there but can't be read isn't saved over on the way out, option save still can
+	autosave := printLoad(rosterPath, true)
 
 	//firstName, lastName, weight, southpaw, reach := getUserInput()
 
 	var firstName string
 	var lastName string
+	var gender string
 	var weight int
 	var southpaw string
 	var reach uint
 	var option int = 0
 
-	for option != 7 {
+	//1 enters a fighter, then one option per division, then print everything, search, find, edit, delete, undo, save, load and end
+	allOption := len(divisions) + 2
+	searchOption := len(divisions) + 3
+	findOption := len(divisions) + 4
+	editOption := len(divisions) + 5
+	deleteOption := len(divisions) + 6
+	undoOption := len(divisions) + 7
+	saveOption := len(divisions) + 8
+	loadOption := len(divisions) + 9
+	endOption := len(divisions) + 10
+
+	for option != endOption {
 
 		fmt.Println("Press 1 to enter fighter information ")
-		fmt.Println("Press 2 to print all Bantamweight fighters")
-		fmt.Println("Press 3 to print all Featherweight fighters")
-		fmt.Println("Press 4 to print all Lightweight fighters")
-		fmt.Println("Press 5 to print all Welterweight fighters")
-		fmt.Println("Press 6 to print all fighter info")
-		fmt.Println("Press 7 to end the program")
+		for i, division := range divisions {
+			fmt.Printf("Press %v to print all %v fighters\n", i+2, division.title())
+		}
+		fmt.Printf("Press %v to print all fighter info\n", allOption)
+		fmt.Printf("Press %v to search, filter and sort the fighters\n", searchOption)
+		fmt.Printf("Press %v to find a fighter by name\n", findOption)
+		fmt.Printf("Press %v to edit a fighter\n", editOption)
+		fmt.Printf("Press %v to delete a fighter\n", deleteOption)
+		fmt.Printf("Press %v to undo the last change\n", undoOption)
+		fmt.Printf("Press %v to save the roster\n", saveOption)
+		fmt.Printf("Press %v to load the roster, replacing the fighters entered so far\n", loadOption)
+		fmt.Printf("Press %v to end the program\n", endOption)
 		fmt.Scanf("%d", &option)
 
-		switch option {
+		switch {
 
-		case 1:
+		case option == 1:
 			fmt.Print("Please enter fighter first name: ")
 			fmt.Scan(&firstName)
 			fmt.Print("\nPlease enter fighter last name: ")
 			fmt.Scan(&lastName)
+			fmt.Print("\nPlease enter if fighter competes as men or women: ")
+			fmt.Scan(&gender)
 			fmt.Print("\nPlease enter fighter weight: ")
 			fmt.Scan(&weight)
 			fmt.Print("\nPlease enter please enter fighter reach: ")
@@ -65,14 +88,19 @@ func main() {
 			var discardNewline string
 			fmt.Scan(&discardNewline)
 			//getUserInput(firstName, lastName, weight, reach, southpaw)
-			isValidName, isValidWeight, isValidReach, isValidSouthPawInput := validateInput(firstName, lastName, weight, reach, southpaw)
+			isValidName, isValidGender, isValidWeight, isValidReach, isValidSouthPawInput := validateInput(firstName, lastName, gender, weight, reach, southpaw)
 
 			if !isValidName {
 				fmt.Println("Please enter a name that is more than or equal to two characters in length")
 				continue
 			}
+			if !isValidGender {
+				fmt.Println("Error, please enter men or women")
+				continue
+			}
 			if !isValidWeight {
-				fmt.Println("Please enter a weight that is 135, 145, 155 or 170")
+				lightest, heaviest := weightRange(gender)
+				fmt.Printf("Please enter a weight that's between or is %v and %v\n", lightest, heaviest)
 				continue
 			}
 			if !isValidReach {
@@ -83,23 +111,38 @@ func main() {
 				fmt.Println("Error, please enter southpaw or orthodox")
 				continue
 			}
-			if isValidName && isValidWeight && isValidReach && isValidSouthPawInput {
-				populateFighters(firstName, lastName, weight, southpaw, reach)
+			if isValidName && isValidGender && isValidWeight && isValidReach && isValidSouthPawInput {
+				populateFighters(firstName, lastName, gender, weight, southpaw, reach)
+			}
+		case option >= 2 && option < allOption:
+			printListing(listing{division: divisions[option-2]})
+		case option == allOption:
+			printListing(listing{})
+		case option == searchOption:
+			searchFighters()
+		case option == findOption:
+			printFound()
+		case option == editOption:
+			editFighter()
+		case option == deleteOption:
+			deleteFighter()
+		case option == undoOption:
+			undoLastChange()
+		case option == saveOption:
+			printSave(rosterPath)
+		case option == loadOption:
+			//a load that skipped fighters mustn't be saved over the file on the way out either
+			autosave = printLoad(rosterPath, false)
+		case option == endOption:
+			//saved on the way out so nothing entered is lost
+			if autosave {
+				printSave(rosterPath)
+			} else {
+				fmt.Println("Not saving over", rosterPath, "because it was not loaded in full, use the save option to replace it")
 			}
-		case 2:
-			printAllBantamweights()
-		case 3:
-			printAllFeatherweights()
-		case 4:
-			printAllLightweights()
-		case 5:
-			printAllWelterweights()
-		case 6:
-			printAllFighterInfo()
-		case 7:
 			fmt.Println("Thanks for using the program, have a good one")
 		default:
-			fmt.Println("Error, please enter 1, 2, 3, 4, 5, 6, or 7")
+			fmt.Printf("Error, please enter a number from 1 to %v\n", endOption)
 		}
 	}
 	//return firstName, lastName, weight, southpaw, reach
@@ -109,128 +152,40 @@ func main() {
 
 // }
 
-func validateInput(firstName string, lastName string, weight int, reach uint, southpaw string) (bool, bool, bool, bool) {
+func validateInput(firstName string, lastName string, gender string, weight int, reach uint, southpaw string) (bool, bool, bool, bool, bool) {
 
 	isValidName := len(firstName) >= 2 && len(lastName) >= 2
-	isValidWeight := weight >= 135 && weight <= 170
+	isValidGender := gender == "men" || gender == "women"
+	//any weight that makes one of the gender's divisions
+	isValidWeight := isValidGender && weight >= minWeight && classify(weight, gender) != nil
 	isValidReach := reach >= 65 && reach <= 85
 	isValidSouthPawInput := southpaw == "southpaw" || southpaw == "orthodox"
 
-	return isValidName, isValidWeight, isValidReach, isValidSouthPawInput
+	return isValidName, isValidGender, isValidWeight, isValidReach, isValidSouthPawInput
 
 }
 
-// func deleteFighters() {
-
-// }
-
 func greetUsers() {
 	fmt.Println("Welcome to the fighter information storing application.")
 }
 
-func populateFighters(firstName string, lastName string, weight int, southpaw string, reach uint) {
+func populateFighters(firstName string, lastName string, gender string, weight int, southpaw string, reach uint) {
 
 	var fighter = FighterInfo{
 		firstName: firstName,
 		lastName:  lastName,
+		gender:    gender,
 		weight:    weight,
 		southpaw:  southpaw,
 		reach:     reach,
 	}
 
-	switch fighter.weight {
-	case 135:
-		bantamWeight = append(bantamWeight, fighter)
-	case 145:
-		featherWeight = append(featherWeight, fighter)
-	case 155:
-		lightWeight = append(lightWeight, fighter)
-	case 170:
-		welterWeight = append(welterWeight, fighter)
-	default:
+	division := classify(fighter.weight, fighter.gender)
+	if division == nil {
 		fmt.Println("Invalid weight.")
+		return
 	}
-}
-
-func printAllFighterInfo() {
-
-	for _, fighter := range bantamWeight {
-		fmt.Println("First Name:", fighter.firstName)
-		fmt.Println("Last Name:", fighter.lastName)
-		fmt.Println("Weight:", fighter.weight)
-		fmt.Println("Southpaw:", fighter.southpaw)
-		fmt.Println("Reach:", fighter.reach)
-		fmt.Println("--------------------")
-	}
-
-	for _, fighter := range featherWeight {
-		fmt.Println("First Name:", fighter.firstName)
-		fmt.Println("Last Name:", fighter.lastName)
-		fmt.Println("Weight:", fighter.weight)
-		fmt.Println("Southpaw:", fighter.southpaw)
-		fmt.Println("Reach:", fighter.reach)
-		fmt.Println("--------------------")
-	}
-
-	for _, fighter := range lightWeight {
-		fmt.Println("First Name:", fighter.firstName)
-		fmt.Println("Last Name:", fighter.lastName)
-		fmt.Println("Weight:", fighter.weight)
-		fmt.Println("Southpaw:", fighter.southpaw)
-		fmt.Println("Reach:", fighter.reach)
-		fmt.Println("--------------------")
-	}
-
-	for _, fighter := range welterWeight {
-		fmt.Println("First Name:", fighter.firstName)
-		fmt.Println("Last Name:", fighter.lastName)
-		fmt.Println("Weight:", fighter.weight)
-		fmt.Println("Southpaw:", fighter.southpaw)
-		fmt.Println("Reach:", fighter.reach)
-		fmt.Println("--------------------")
-	}
-}
-
-func printAllBantamweights() {
-	for _, fighter := range bantamWeight {
-		fmt.Println("First Name:", fighter.firstName)
-		fmt.Println("Last Name:", fighter.lastName)
-		fmt.Println("Weight:", fighter.weight)
-		fmt.Println("Southpaw:", fighter.southpaw)
-		fmt.Println("Reach:", fighter.reach)
-		fmt.Println("--------------------")
-	}
-}
-
-func printAllFeatherweights() {
-	for _, fighter := range featherWeight {
-		fmt.Println("First Name:", fighter.firstName)
-		fmt.Println("Last Name:", fighter.lastName)
-		fmt.Println("Weight:", fighter.weight)
-		fmt.Println("Southpaw:", fighter.southpaw)
-		fmt.Println("Reach:", fighter.reach)
-		fmt.Println("--------------------")
-	}
-}
-
-func printAllLightweights() {
-	for _, fighter := range lightWeight {
-		fmt.Println("First Name:", fighter.firstName)
-		fmt.Println("Last Name:", fighter.lastName)
-		fmt.Println("Weight:", fighter.weight)
-		fmt.Println("Southpaw:", fighter.southpaw)
-		fmt.Println("Reach:", fighter.reach)
-		fmt.Println("--------------------")
-	}
-}
-
-func printAllWelterweights() {
-	for _, fighter := range welterWeight {
-		fmt.Println("First Name:", fighter.firstName)
-		fmt.Println("Last Name:", fighter.lastName)
-		fmt.Println("Weight:", fighter.weight)
-		fmt.Println("Southpaw:", fighter.southpaw)
-		fmt.Println("Reach:", fighter.reach)
-		fmt.Println("--------------------")
-	}
+	remember(fmt.Sprintf("adding %v %v", fighter.firstName, fighter.lastName))
+	division.fighters = append(division.fighters, fighter)
+	fmt.Printf("%v %v goes in %v\n", fighter.firstName, fighter.lastName, division.title())
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rosterPath is where the roster is saved and loaded from, set with -roster
+var rosterPath = "fighters.json"
+
+// fighterRecord is one fighter as it is saved in the roster file
+type fighterRecord struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Gender    string `json:"gender"`
+	Weight    int    `json:"weight"`
+	Southpaw  string `json:"southpaw"`
+	Reach     uint   `json:"reach"`
+}
+
+// saveRoster writes every fighter to path. It writes a temp file next to it and renames it into place,
+// so a crash half way through leaves the old roster as it was.
+func saveRoster(path string) error {
+	records := make([]fighterRecord, 0)
+	for _, division := range divisions {
+		for _, fighter := range division.fighters {
+			records = append(records, fighterRecord{
+				FirstName: fighter.firstName,
+				LastName:  fighter.lastName,
+				Gender:    fighter.gender,
+				Weight:    fighter.weight,
+				Southpaw:  fighter.southpaw,
+				Reach:     fighter.reach,
+			})
+		}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".fighters-*.tmp")
+	if err != nil {
+		return err
+	}
+	//once the rename has happened there is nothing left to remove
+	defer os.Remove(temp.Name())
+	if err := temp.Chmod(0644); err != nil {
+		temp.Close()
+		return err
+	}
+	if _, err := temp.Write(append(data, '\n')); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// loadRoster replaces the roster with the fighters in path. Every record goes through validateInput,
+// the ones that fail are left out and returned as problems.
+func loadRoster(path string) (int, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	var records []fighterRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return 0, nil, fmt.Errorf("%v is not a roster file: %w", path, err)
+	}
+
+	//loading replaces everyone, so it is the change undo goes back from
+	remember(fmt.Sprintf("loading %v", path))
+	for _, division := range divisions {
+		division.fighters = nil
+	}
+	loaded := 0
+	var problems []string
+	for i, record := range records {
+		isValidName, isValidGender, isValidWeight, isValidReach, isValidSouthPawInput := validateInput(record.FirstName, record.LastName, record.Gender, record.Weight, record.Reach, record.Southpaw)
+		var wrong []string
+		if !isValidName {
+			wrong = append(wrong, "name")
+		}
+		if !isValidGender {
+			wrong = append(wrong, "gender")
+		}
+		if !isValidWeight {
+			wrong = append(wrong, "weight")
+		}
+		if !isValidReach {
+			wrong = append(wrong, "reach")
+		}
+		if !isValidSouthPawInput {
+			wrong = append(wrong, "southpaw")
+		}
+		if len(wrong) > 0 {
+			problems = append(problems, fmt.Sprintf("record %v (%v %v) has a bad %v", i+1, record.FirstName, record.LastName, joinWords(wrong)))
+			continue
+		}
+		division := classify(record.Weight, record.Gender)
+		division.fighters = append(division.fighters, FighterInfo{
+			firstName: record.FirstName,
+			lastName:  record.LastName,
+			gender:    record.Gender,
+			weight:    record.Weight,
+			southpaw:  record.Southpaw,
+			reach:     record.Reach,
+		})
+		loaded++
+	}
+	return loaded, problems, nil
+}
+
+// joinWords lists words like name, weight and reach
+func joinWords(words []string) string {
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		return words[0]
+	}
+	list := words[0]
+	for _, word := range words[1 : len(words)-1] {
+		list += ", " + word
+	}
+	return list + " and " + words[len(words)-1]
+}
+
+// printLoad loads the roster and reports what was skipped, at startup a roster that isn't there yet is fine.
+// It returns false if the file couldn't be read or any fighter in it was skipped, so saving on exit doesn't drop them from the file.
+func printLoad(path string, startup bool) bool {
+	loaded, problems, err := loadRoster(path)
+	if startup && errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	if err != nil {
+		fmt.Println("Could not load the roster:", err)
+		return false
+	}
+	if startup {
+		lastChange = nil //there is nothing before the roster the program starts with
+	}
+	fmt.Printf("Loaded %v fighters from %v\n", loaded, path)
+	for _, problem := range problems {
+		fmt.Println("Skipped", problem)
+	}
+	return len(problems) == 0
+}
+
+func printSave(path string) {
+	if err := saveRoster(path); err != nil {
+		fmt.Println("Could not save the roster:", err)
+		return
+	}
+	fmt.Println("Saved the roster to", path)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+// BloomFilter structure (bit set with k hash functions, a false answer from Test means the key was never added)
+type BloomFilter struct {
+	bits  []uint64
+	m     uint64 //number of bits
+	k     uint64 //number of hash functions
+	count uint64 //number of keys added
+}
+
+// CountingBloomFilter structure (same as BloomFilter but every bit is a counter so keys can be removed)
+type CountingBloomFilter struct {
+	counters []uint8
+	m        uint64
+	k        uint64
+	count    uint64
+}
+
+// bloomHeaderSize is m, k and count written as three uint64s in front of the filter data
+const bloomHeaderSize = 24
+
+var (
+	// ErrIncompatibleFilters is returned when merging filters that don't have the same m and k
+	ErrIncompatibleFilters = errors.New("bloom filters have different sizes or hash counts")
+	// ErrBadFilterData is returned when UnmarshalBinary gets data it can't read
+	ErrBadFilterData = errors.New("bloom filter data is malformed")
+)
+
+// bloomParams works out how many bits and hash functions are needed for n items at false positive rate p
+func bloomParams(n uint64, p float64) (uint64, uint64) {
+	if n == 0 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return m, k
+}
+
+// bloomLocations derives the k bit positions for a key from two fnv hashes (h1 + i*h2)
+func bloomLocations(key string, m uint64, k uint64) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h.Write([]byte{0xff}) //one more byte gives a second, different hash
+	h2 := h.Sum64() | 1   //odd so it never gets stuck on the same bit
+
+	locations := make([]uint64, k)
+	for i := uint64(0); i < k; i++ {
+		locations[i] = (h1 + i*h2) % m
+	}
+	return locations
+}
+
+// estimateFalsePositiveRate gives the chance that a key that was never added still tests true
+func estimateFalsePositiveRate(m uint64, k uint64, set uint64) float64 {
+	return math.Pow(float64(set)/float64(m), float64(k))
+}
+
+// NewBloomFilter will create a filter sized for n expected keys at false positive rate p
+func NewBloomFilter(n uint64, p float64) *BloomFilter {
+	m, k := bloomParams(n, p)
+	return &BloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// Add will set the k bits for the key
+func (b *BloomFilter) Add(key string) {
+	for _, loc := range bloomLocations(key, b.m, b.k) {
+		b.bits[loc/64] |= 1 << (loc % 64)
+	}
+	b.count++
+}
+
+// Test will return false if the key was definitely never added
+func (b *BloomFilter) Test(key string) bool {
+	for _, loc := range bloomLocations(key, b.m, b.k) {
+		if b.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge will add every key from other into b, both filters need the same m and k
+func (b *BloomFilter) Merge(other *BloomFilter) error {
+	if b.m != other.m || b.k != other.k {
+		return ErrIncompatibleFilters
+	}
+	for i := range b.bits {
+		b.bits[i] |= other.bits[i]
+	}
+	b.count += other.count
+	return nil
+}
+
+// FalsePositiveRate will estimate the current false positive rate from how many bits are set
+func (b *BloomFilter) FalsePositiveRate() float64 {
+	var set uint64
+	for _, word := range b.bits {
+		for ; word != 0; word &= word - 1 {
+			set++
+		}
+	}
+	return estimateFalsePositiveRate(b.m, b.k, set)
+}
+
+// MarshalBinary will write the filter as m, k, count and then the bit words
+func (b *BloomFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, bloomHeaderSize+8*len(b.bits))
+	binary.BigEndian.PutUint64(data[0:], b.m)
+	binary.BigEndian.PutUint64(data[8:], b.k)
+	binary.BigEndian.PutUint64(data[16:], b.count)
+	for i, word := range b.bits {
+		binary.BigEndian.PutUint64(data[bloomHeaderSize+8*i:], word)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary will read a filter written by MarshalBinary
+func (b *BloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < bloomHeaderSize {
+		return ErrBadFilterData
+	}
+	m := binary.BigEndian.Uint64(data[0:])
+	k := binary.BigEndian.Uint64(data[8:])
+	//check m against the data before rounding it up to words, a huge m would wrap around to a small word count
+	if m == 0 || k == 0 || k > m || m > uint64(len(data)-bloomHeaderSize)*8 {
+		return ErrBadFilterData
+	}
+	words := (m + 63) / 64
+	if uint64(len(data)-bloomHeaderSize) != 8*words {
+		return ErrBadFilterData
+	}
+	b.m, b.k = m, k
+	b.count = binary.BigEndian.Uint64(data[16:])
+	b.bits = make([]uint64, words)
+	for i := range b.bits {
+		b.bits[i] = binary.BigEndian.Uint64(data[bloomHeaderSize+8*i:])
+	}
+	return nil
+}
+
+// NewCountingBloomFilter will create a counting filter sized for n expected keys at false positive rate p
+func NewCountingBloomFilter(n uint64, p float64) *CountingBloomFilter {
+	m, k := bloomParams(n, p)
+	return &CountingBloomFilter{counters: make([]uint8, m), m: m, k: k}
+}
+
+// Add will bump the k counters for the key, counters stop at 255 instead of wrapping
+func (c *CountingBloomFilter) Add(key string) {
+	for _, loc := range bloomLocations(key, c.m, c.k) {
+		if c.counters[loc] < math.MaxUint8 {
+			c.counters[loc]++
+		}
+	}
+	c.count++
+}
+
+// Remove will drop the k counters for the key, it returns false and changes nothing if the key isn't in the filter
+func (c *CountingBloomFilter) Remove(key string) bool {
+	if !c.Test(key) {
+		return false
+	}
+	for _, loc := range bloomLocations(key, c.m, c.k) {
+		//a counter that hit 255 has lost track of how many keys use it so it is left alone
+		if c.counters[loc] < math.MaxUint8 {
+			c.counters[loc]--
+		}
+	}
+	c.count--
+	return true
+}
+
+// Test will return false if the key is definitely not in the filter
+func (c *CountingBloomFilter) Test(key string) bool {
+	for _, loc := range bloomLocations(key, c.m, c.k) {
+		if c.counters[loc] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge will add the counts from other into c, both filters need the same m and k
+func (c *CountingBloomFilter) Merge(other *CountingBloomFilter) error {
+	if c.m != other.m || c.k != other.k {
+		return ErrIncompatibleFilters
+	}
+	for i, n := range other.counters {
+		sum := int(c.counters[i]) + int(n)
+		if sum > math.MaxUint8 {
+			sum = math.MaxUint8
+		}
+		c.counters[i] = uint8(sum)
+	}
+	c.count += other.count
+	return nil
+}
+
+// FalsePositiveRate will estimate the current false positive rate from how many counters are above zero
+func (c *CountingBloomFilter) FalsePositiveRate() float64 {
+	var set uint64
+	for _, n := range c.counters {
+		if n > 0 {
+			set++
+		}
+	}
+	return estimateFalsePositiveRate(c.m, c.k, set)
+}
+
+// MarshalBinary will write the filter as m, k, count and then one byte per counter
+func (c *CountingBloomFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, bloomHeaderSize, bloomHeaderSize+len(c.counters))
+	binary.BigEndian.PutUint64(data[0:], c.m)
+	binary.BigEndian.PutUint64(data[8:], c.k)
+	binary.BigEndian.PutUint64(data[16:], c.count)
+	return append(data, c.counters...), nil
+}
+
+// UnmarshalBinary will read a counting filter written by MarshalBinary
+func (c *CountingBloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < bloomHeaderSize {
+		return ErrBadFilterData
+	}
+	m := binary.BigEndian.Uint64(data[0:])
+	k := binary.BigEndian.Uint64(data[8:])
+	//m is compared with the data as it is, there is no rounding up that could wrap around
+	if m == 0 || k == 0 || k > m || uint64(len(data)-bloomHeaderSize) != m {
+		return ErrBadFilterData
+	}
+	c.m, c.k = m, k
+	c.count = binary.BigEndian.Uint64(data[16:])
+	c.counters = append([]uint8(nil), data[bloomHeaderSize:]...)
+	return nil
+}
+
+// FilteredTable structure (HashTable with a counting bloom filter in front so Search can skip keys that were never added)
+type FilteredTable struct {
+	table  *HashTable
+	filter *CountingBloomFilter
+}
+
+// NewFilteredTable will create a HashTable and a counting filter sized for n keys at false positive rate p
+func NewFilteredTable(n uint64, p float64) *FilteredTable {
+	return &FilteredTable{table: Init(), filter: NewCountingBloomFilter(n, p)}
+}
+
+// Insert will add the key to the table and the filter, it returns false if the key was already there
+func (f *FilteredTable) Insert(key string) bool {
+	//only count keys the table actually added, counting one twice would leave a count behind after Delete
+	if !f.table.Insert(key) {
+		return false
+	}
+	f.filter.Add(key)
+	return true
+}
+
+// Search will only look in the table if the filter says the key might be there
+func (f *FilteredTable) Search(key string) bool {
+	if !f.filter.Test(key) {
+		return false
+	}
+	return f.table.Search(key)
+}
+
+// Delete will remove the key from the table and the filter, it returns ErrNotFound if the key isn't there
+func (f *FilteredTable) Delete(key string) error {
+	if err := f.table.Delete(key); err != nil {
+		return err
+	}
+	f.filter.Remove(key)
+	return nil
+}
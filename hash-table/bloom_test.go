@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// filter is what the plain and counting Bloom filters have in common
+type filter interface {
+	Add(key string)
+	Test(key string) bool
+	FalsePositiveRate() float64
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(data []byte) error
+}
+
+var filterKinds = []struct {
+	name  string
+	new   func(n uint64, p float64) filter
+	empty func() filter
+}{
+	{"bloom", func(n uint64, p float64) filter { return NewBloomFilter(n, p) }, func() filter { return &BloomFilter{} }},
+	{"counting", func(n uint64, p float64) filter { return NewCountingBloomFilter(n, p) }, func() filter { return &CountingBloomFilter{} }},
+}
+
+func TestBloomFilterFalsePositives(t *testing.T) {
+	tests := []struct {
+		n uint64
+		p float64
+	}{
+		{100, 0.1},
+		{1000, 0.01},
+		{5000, 0.001},
+	}
+	for _, kind := range filterKinds {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%v n=%v p=%v", kind.name, test.n, test.p), func(t *testing.T) {
+				f := kind.new(test.n, test.p)
+				if rate := f.FalsePositiveRate(); rate != 0 {
+					t.Errorf("an empty filter estimates a false positive rate of %v", rate)
+				}
+				keys := testKeys(int(test.n))
+				for _, key := range keys {
+					f.Add(key)
+				}
+				for _, key := range keys {
+					if !f.Test(key) {
+						t.Fatalf("%v was added but Test says it wasn't", key)
+					}
+				}
+
+				const tries = 20000
+				falsePositives := 0
+				for i := 0; i < tries; i++ {
+					if f.Test(fmt.Sprintf("other-%v", i)) {
+						falsePositives++
+					}
+				}
+				measured := float64(falsePositives) / tries
+				estimated := f.FalsePositiveRate()
+				//filled to n the filter should be close to the rate it was sized for
+				if estimated < test.p/3 || estimated > test.p*3 {
+					t.Errorf("estimated false positive rate %v, sized for %v", estimated, test.p)
+				}
+				if measured > test.p*3+0.002 {
+					t.Errorf("measured false positive rate %v, sized for %v", measured, test.p)
+				}
+			})
+		}
+	}
+}
+
+func TestBloomFilterMarshalRoundTrip(t *testing.T) {
+	for _, kind := range filterKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			f := kind.new(500, 0.01)
+			keys := testKeys(500)
+			for _, key := range keys {
+				f.Add(key)
+			}
+			data, err := f.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			read := kind.empty()
+			if err := read.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range keys {
+				if !read.Test(key) {
+					t.Fatalf("%v got lost on the way through MarshalBinary", key)
+				}
+			}
+			if read.FalsePositiveRate() != f.FalsePositiveRate() {
+				t.Errorf("the false positive rate changed from %v to %v", f.FalsePositiveRate(), read.FalsePositiveRate())
+			}
+			again, _ := read.MarshalBinary()
+			if string(again) != string(data) {
+				t.Error("marshalling the read filter gives different bytes")
+			}
+		})
+	}
+}
+
+// filterData builds marshalled filter data with the given header and body length
+func filterData(m, k uint64, body int) []byte {
+	data := make([]byte, bloomHeaderSize+body)
+	binary.BigEndian.PutUint64(data[0:], m)
+	binary.BigEndian.PutUint64(data[8:], k)
+	return data
+}
+
+func TestBloomFilterUnmarshalBadData(t *testing.T) {
+	tests := []struct {
+		name     string
+		bloom    []byte
+		counting []byte
+	}{
+		{"empty", nil, nil},
+		{"cut off header", make([]byte, bloomHeaderSize-1), make([]byte, bloomHeaderSize-1)},
+		{"no bits", filterData(0, 1, 0), filterData(0, 1, 0)},
+		{"no hash functions", filterData(64, 0, 8), filterData(64, 0, 64)},
+		{"more hash functions than bits", filterData(64, 65, 8), filterData(64, 65, 64)},
+		{"truncated body", filterData(128, 3, 8), filterData(128, 3, 64)},
+		{"oversized body", filterData(64, 3, 16), filterData(64, 3, 65)},
+		//(m+63)/64 wraps around to 0 words, so an empty body used to look right
+		{"m overflows the word count", filterData(math.MaxUint64, 3, 0), filterData(math.MaxUint64, 3, 0)},
+		{"m just under the overflow", filterData(math.MaxUint64-63, 3, 8), filterData(math.MaxUint64-63, 3, 8)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b BloomFilter
+			if err := b.UnmarshalBinary(test.bloom); !errors.Is(err, ErrBadFilterData) {
+				t.Errorf("BloomFilter gave %v, want ErrBadFilterData", err)
+			}
+			var c CountingBloomFilter
+			if err := c.UnmarshalBinary(test.counting); !errors.Is(err, ErrBadFilterData) {
+				t.Errorf("CountingBloomFilter gave %v, want ErrBadFilterData", err)
+			}
+		})
+	}
+}
+
+func TestBloomFilterMerge(t *testing.T) {
+	a, b := NewBloomFilter(200, 0.01), NewBloomFilter(200, 0.01)
+	ca, cb := NewCountingBloomFilter(200, 0.01), NewCountingBloomFilter(200, 0.01)
+	keys := testKeys(200)
+	for i, key := range keys {
+		if i%2 == 0 {
+			a.Add(key)
+			ca.Add(key)
+		} else {
+			b.Add(key)
+			cb.Add(key)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.Merge(cb); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if !a.Test(key) || !ca.Test(key) {
+			t.Fatalf("%v is missing after the merge", key)
+		}
+	}
+	if a.count != 200 || ca.count != 200 {
+		t.Errorf("merged counts are %v and %v, want 200", a.count, ca.count)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"different sizes", a.Merge(NewBloomFilter(2000, 0.01))},
+		{"different hash counts", a.Merge(&BloomFilter{bits: make([]uint64, len(a.bits)), m: a.m, k: a.k + 1})},
+		{"counting different sizes", ca.Merge(NewCountingBloomFilter(2000, 0.01))},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, ErrIncompatibleFilters) {
+			t.Errorf("%v: got %v, want ErrIncompatibleFilters", test.name, test.err)
+		}
+	}
+}
+
+func TestCountingBloomFilterRemove(t *testing.T) {
+	c := NewCountingBloomFilter(100, 0.01)
+	c.Add("kept")
+	c.Add("removed")
+	if !c.Remove("removed") {
+		t.Fatal("Remove didn't find a key that was added")
+	}
+	if c.Test("removed") || !c.Test("kept") {
+		t.Errorf("after Remove, Test(removed) = %v and Test(kept) = %v", c.Test("removed"), c.Test("kept"))
+	}
+	if c.Remove("never added") || c.count != 1 {
+		t.Errorf("removing a key that was never added changed the filter")
+	}
+
+	//a counter stuck at 255 has lost count, so removing a key that uses it must not bring it down
+	for i := 0; i < 300; i++ {
+		c.Add("popular")
+	}
+	c.Remove("popular")
+	if !c.Test("popular") {
+		t.Error("removing one copy of a saturated key made it disappear")
+	}
+}
+
+func TestFilteredTable(t *testing.T) {
+	f := NewFilteredTable(100, 0.01)
+	keys := testKeys(100)
+	for _, key := range keys {
+		if !f.Insert(key) {
+			t.Fatalf("%v was already there", key)
+		}
+	}
+	if f.Insert(keys[0]) {
+		t.Error("a key was inserted twice")
+	}
+	if err := f.Delete(keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if f.Search(keys[0]) || !f.Search(keys[1]) {
+		t.Error("Search is wrong after Delete")
+	}
+	if err := f.Delete(keys[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting twice gave %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import "hash/maphash"
+
+const (
+	// CuckooSize is how many slots each of the two cuckoo tables starts with
+	CuckooSize = 8
+	// MaxKicks bounds the eviction chain before a key gets pushed into the stash
+	MaxKicks = 16
+	// StashSize is how many keys the stash holds before the table rehashes
+	StashSize = 4
+)
+
+// CuckooTable structure (two tables with their own hash function, every key lives in one of its two slots or the stash)
+type CuckooTable struct {
+	tables [2][]cuckooSlot
+	seeds  [2]maphash.Seed
+	stash  []string
+	count  int
+}
+
+// cuckooSlot structure (a slot is either empty or holds one key)
+type cuckooSlot struct {
+	key  string
+	used bool
+}
+
+// NewCuckoo will create an empty cuckoo table with fresh seeds
+func NewCuckoo() *CuckooTable {
+	c := &CuckooTable{}
+	c.reset(CuckooSize)
+	return c
+}
+
+// reset empties the table, picks new seeds and sizes both tables to size slots
+func (c *CuckooTable) reset(size int) {
+	c.tables[0] = make([]cuckooSlot, size)
+	c.tables[1] = make([]cuckooSlot, size)
+	c.seeds[0] = maphash.MakeSeed()
+	c.seeds[1] = maphash.MakeSeed()
+	c.stash = nil
+	c.count = 0
+}
+
+// slot gives the index the key hashes to in table t
+func (c *CuckooTable) slot(t int, key string) int {
+	return int(maphash.String(c.seeds[t], key) % uint64(len(c.tables[t])))
+}
+
+// Insert will add the key, kicking other keys to their second slot if it has to, it returns false if the key was already there
+func (c *CuckooTable) Insert(key string) bool {
+	if c.Search(key) {
+		return false
+	}
+	if leftover, ok := c.place(key); !ok {
+		c.rehash(leftover)
+	}
+	return true
+}
+
+// place runs the eviction chain for key, if the chain and the stash are both full
+// it returns the key that got kicked out last and false
+func (c *CuckooTable) place(key string) (string, bool) {
+	current := key
+	t := 0
+	for kicks := 0; kicks < MaxKicks; kicks++ {
+		//if either of the two slots is free we are done
+		for _, try := range [2]int{t, 1 - t} {
+			i := c.slot(try, current)
+			if !c.tables[try][i].used {
+				c.tables[try][i] = cuckooSlot{key: current, used: true}
+				c.count++
+				return "", true
+			}
+		}
+		//both slots are taken so kick out the key sitting in table t, it will try its other slot next
+		i := c.slot(t, current)
+		current, c.tables[t][i].key = c.tables[t][i].key, current
+		t = 1 - t
+	}
+	if len(c.stash) < StashSize {
+		c.stash = append(c.stash, current)
+		c.count++
+		return "", true
+	}
+	return current, false
+}
+
+// rehash picks new seeds and puts every key back, growing the tables if new seeds alone don't do it
+func (c *CuckooTable) rehash(extra string) {
+	keys := append(c.keys(), extra)
+	size := len(c.tables[0])
+	for attempt := 1; ; attempt++ {
+		if attempt%4 == 0 {
+			size *= 2 //a few seeds in a row failed so the tables are too full
+		}
+		c.reset(size)
+		placedAll := true
+		for _, k := range keys {
+			if _, ok := c.place(k); !ok {
+				placedAll = false
+				break
+			}
+		}
+		if placedAll {
+			return
+		}
+	}
+}
+
+// keys will return every key in both tables and the stash
+func (c *CuckooTable) keys() []string {
+	result := make([]string, 0, c.count)
+	for t := range c.tables {
+		for _, s := range c.tables[t] {
+			if s.used {
+				result = append(result, s.key)
+			}
+		}
+	}
+	return append(result, c.stash...)
+}
+
+// Search will return true if the key is in one of its two slots or in the stash
+func (c *CuckooTable) Search(key string) bool {
+	for t := range c.tables {
+		s := c.tables[t][c.slot(t, key)]
+		if s.used && s.key == key {
+			return true
+		}
+	}
+	for _, k := range c.stash {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete will remove the key from its slot or from the stash, it returns ErrNotFound if the key isn't there
+func (c *CuckooTable) Delete(key string) error {
+	for t := range c.tables {
+		i := c.slot(t, key)
+		if c.tables[t][i].used && c.tables[t][i].key == key {
+			c.tables[t][i] = cuckooSlot{}
+			c.count--
+			return nil
+		}
+	}
+	for i, k := range c.stash {
+		if k == key {
+			c.stash = append(c.stash[:i], c.stash[i+1:]...)
+			c.count--
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Len will return how many keys are stored
+func (c *CuckooTable) Len() int {
+	return c.count
+}
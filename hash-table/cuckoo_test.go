@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCuckooInsertSearchDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   int
+		rehash bool //enough keys that the tables have to grow
+	}{
+		{"empty", 0, false},
+		{"one key", 1, false},
+		{"fills the starting tables", CuckooSize, false},
+		{"past the stash", 2*CuckooSize + StashSize + 1, true},
+		{"many rehashes", 5000, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewCuckoo()
+			keys := testKeys(test.keys)
+			for _, key := range keys {
+				if !c.Insert(key) {
+					t.Fatalf("%v was already there", key)
+				}
+			}
+			if c.Len() != len(keys) {
+				t.Fatalf("Len is %v, want %v", c.Len(), len(keys))
+			}
+			if test.rehash && len(c.tables[0]) == CuckooSize {
+				t.Fatalf("%v keys fit in the starting tables and stash, the test doesn't rehash", len(keys))
+			}
+			if len(c.stash) > StashSize {
+				t.Errorf("the stash holds %v keys, it only has room for %v", len(c.stash), StashSize)
+			}
+			for _, key := range keys {
+				if !c.Search(key) {
+					t.Fatalf("%v is missing", key)
+				}
+				if c.Insert(key) {
+					t.Fatalf("%v was inserted twice", key)
+				}
+			}
+			if c.Len() != len(keys) {
+				t.Fatalf("duplicate inserts changed Len to %v", c.Len())
+			}
+
+			//deleting after the rehash has to find keys with the new seeds and sizes, wherever they ended up
+			for _, key := range keys[:len(keys)/2] {
+				if err := c.Delete(key); err != nil {
+					t.Fatalf("deleting %v: %v", key, err)
+				}
+			}
+			for i, key := range keys {
+				if want := i >= len(keys)/2; c.Search(key) != want {
+					t.Errorf("after deleting the first half, Search(%v) = %v", key, !want)
+				}
+			}
+			if c.Len() != len(keys)-len(keys)/2 {
+				t.Errorf("Len is %v after deleting, want %v", c.Len(), len(keys)-len(keys)/2)
+			}
+			if err := c.Delete(testKeys(1)[0] + "-missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleting a missing key gave %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestCuckooStashIsSearchedAndDeleted(t *testing.T) {
+	c := NewCuckoo()
+	c.stash = []string{"stashed"}
+	c.count = 1
+	if !c.Search("stashed") || c.Insert("stashed") {
+		t.Fatal("a key in the stash wasn't found")
+	}
+	if err := c.Delete("stashed"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Search("stashed") || c.Len() != 0 || len(c.stash) != 0 {
+		t.Errorf("the stashed key is still there after Delete")
+	}
+}
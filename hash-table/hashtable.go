@@ -0,0 +1,135 @@
+package main
+
+import "errors"
+
+// ErrNotFound is returned when deleting a key that isn't in the table
+var ErrNotFound = errors.New("key not found")
+
+const ArraySize = 7
+
+// HashTable structure
+type HashTable struct {
+	array [ArraySize]*bucket
+}
+
+// bucket structure (will be linked list, in each slot/index of the HashTable)
+type bucket struct {
+	head *bucketNode
+}
+
+// bucketNode structure (node is each key/value)
+type bucketNode struct {
+	key  string
+	next *bucketNode
+}
+
+// for HashTable
+func hash(key string) int {
+	//get ascii code for each character, sum it up and divide it by the array size and get the remainder
+	sum := 0 //initialize sum
+	//we need a for loop to loop through each character of the key
+	for _, v := range key {
+		sum += int(v) //so each letter is getting changed to an integer and getting added up
+	}
+	return sum % ArraySize
+}
+
+// // insert will take in a key and add it to the hash table array, it returns false if the key was already there
+func (h *HashTable) Insert(key string) bool {
+	index := hash(key)
+	if h.array[index] == nil {
+		h.array[index] = &bucket{} //a HashTable that didn't come from Init starts out without buckets
+	}
+	return h.array[index].insert(key)
+}
+
+// // search will take in a key and return true if that key is stored in the hash table
+func (h *HashTable) Search(key string) bool {
+	index := hash(key)
+	return h.array[index].search(key)
+}
+
+// // delete will take in a key and delete it from the hash table, it returns ErrNotFound if the key isn't there
+func (h *HashTable) Delete(key string) error {
+	index := hash(key)
+	return h.array[index].delete(key)
+}
+
+// for bucket
+// insert will take in a key, create a node with the key and insert the node in the bucket
+func (b *bucket) insert(k string) bool {
+	if b.search(k) {
+		return false //the bucket node already exists
+	}
+	newNode := &bucketNode{key: k} //initializes and sets the newNode to the address of a bucketNode with they key: k
+	newNode.next = b.head          //sets the next attribute to the head node, the new node becomes the first node
+	b.head = newNode               //makes the new node the first element in the linked list, or new head of the bucket
+	return true
+}
+
+// search will take in a key and return true if the key is found
+func (b *bucket) search(k string) bool {
+	if b == nil {
+		return false
+	}
+	currentNode := b.head
+	//going to keep on looping until we find a match, until the current node is empty
+	for currentNode != nil {
+		if currentNode.key == k {
+			return true
+		}
+		currentNode = currentNode.next
+	}
+	return false
+
+}
+
+// delete will take in a key and remove its node from the bucket
+func (b *bucket) delete(k string) error {
+	//an empty bucket has no head to look at
+	if b == nil || b.head == nil {
+		return ErrNotFound
+	}
+	//we don't want to miss if the matching key is the head
+	if b.head.key == k { //if the head node is they key we want to delete we reset the head of this bucket to the second node
+		b.head = b.head.next
+		return nil
+	}
+
+	previousNode := b.head
+	//the previousNode.next is the current node
+	for previousNode.next != nil {
+		if previousNode.next.key == k {
+			//delete
+			previousNode.next = previousNode.next.next
+			return nil
+		}
+		previousNode = previousNode.next
+	}
+	return ErrNotFound
+}
+
+// keys will return every key stored in the hash table
+func (h *HashTable) keys() []string {
+	result := []string{}
+	for _, b := range h.array {
+		if b == nil {
+			continue
+		}
+		for currentNode := b.head; currentNode != nil; currentNode = currentNode.next {
+			result = append(result, currentNode.key)
+		}
+	}
+	return result
+}
+
+// init will create a bucket in each slot of the hash table
+func Init() *HashTable {
+	result := &HashTable{}
+	//for loop that goes through each i of the hash table
+	for i := range result.array {
+		//creates a bucket at each index
+		result.array[i] = &bucket{}
+	}
+	return result //returns the hashtable with the buckets at each slot
+}
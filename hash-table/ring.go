@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is how many virtual nodes each HashTable gets on the ring
+const DefaultReplicas = 100
+
+// ErrEmptyRing is returned when inserting into a ring that has no nodes yet
+var ErrEmptyRing = errors.New("ring has no nodes")
+
+// Ring structure (consistent hashing ring that splits keys across named HashTables)
+type Ring struct {
+	replicas int
+	points   []uint32              //sorted hashes of every virtual node on the ring
+	owners   map[uint32]string     //which node name owns each virtual node hash
+	nodes    map[string]*HashTable //the actual HashTable behind each node name
+}
+
+// NewRing will create an empty ring where every node gets replicas virtual nodes
+func NewRing(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &Ring{
+		replicas: replicas,
+		owners:   make(map[uint32]string),
+		nodes:    make(map[string]*HashTable),
+	}
+}
+
+// ringHash places keys and virtual nodes on the ring
+func ringHash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// virtualNode gives the name used to hash the i-th virtual node of a node
+func virtualNode(name string, i int) string {
+	return name + "#" + strconv.Itoa(i)
+}
+
+// AddNode will put a new HashTable on the ring and move over only the keys it now owns
+func (r *Ring) AddNode(name string) error {
+	if _, ok := r.nodes[name]; ok {
+		return fmt.Errorf("node %q is already on the ring", name)
+	}
+
+	//the nodes that currently own the spots right after the new virtual nodes are the only ones that can lose keys
+	donors := make(map[string]bool)
+	if len(r.points) > 0 {
+		for i := 0; i < r.replicas; i++ {
+			donors[r.ownerOf(ringHash(virtualNode(name, i)))] = true
+		}
+	}
+
+	r.nodes[name] = Init()
+	for i := 0; i < r.replicas; i++ {
+		point := ringHash(virtualNode(name, i))
+		if _, taken := r.owners[point]; taken {
+			continue //hash collision with another virtual node, the first one keeps the spot
+		}
+		r.owners[point] = name
+		r.points = append(r.points, point)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+
+	for donor := range donors {
+		table := r.nodes[donor]
+		for _, key := range table.keys() {
+			if r.Node(key) == name {
+				table.Delete(key)
+				r.nodes[name].Insert(key)
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveNode will take a HashTable off the ring and hand its keys to the nodes that now own them
+func (r *Ring) RemoveNode(name string) error {
+	table, ok := r.nodes[name]
+	if !ok {
+		return fmt.Errorf("node %q is not on the ring", name)
+	}
+	if len(r.nodes) == 1 && len(table.keys()) > 0 {
+		return fmt.Errorf("node %q is the last node on the ring and still owns keys", name)
+	}
+
+	points := r.points[:0]
+	for _, point := range r.points {
+		if r.owners[point] == name {
+			delete(r.owners, point)
+			continue
+		}
+		points = append(points, point)
+	}
+	r.points = points
+	delete(r.nodes, name)
+
+	for _, key := range table.keys() {
+		r.nodes[r.Node(key)].Insert(key)
+	}
+	return nil
+}
+
+// ownerOf walks clockwise from a hash to the first virtual node and returns its node name
+func (r *Ring) ownerOf(h uint32) string {
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0 //went past the last virtual node so wrap around to the start of the ring
+	}
+	return r.owners[r.points[i]]
+}
+
+// Node will return the name of the node that owns the key, or "" if the ring is empty
+func (r *Ring) Node(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	return r.ownerOf(ringHash(key))
+}
+
+// Insert will add the key to the HashTable of the node that owns it, it returns false if the key was already there
+func (r *Ring) Insert(key string) (bool, error) {
+	name := r.Node(key)
+	if name == "" {
+		return false, ErrEmptyRing
+	}
+	return r.nodes[name].Insert(key), nil
+}
+
+// Search will return true if the key is stored on the node that owns it
+func (r *Ring) Search(key string) bool {
+	name := r.Node(key)
+	if name == "" {
+		return false
+	}
+	return r.nodes[name].Search(key)
+}
+
+// Delete will remove the key from the node that owns it, it returns ErrNotFound if the key isn't there
+func (r *Ring) Delete(key string) error {
+	name := r.Node(key)
+	if name == "" {
+		return ErrNotFound
+	}
+	return r.nodes[name].Delete(key)
+}
+
+// KeyCounts will return how many keys each node currently owns
+func (r *Ring) KeyCounts() map[string]int {
+	counts := make(map[string]int, len(r.nodes))
+	for name, table := range r.nodes {
+		counts[name] = len(table.keys())
+	}
+	return counts
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testKeys makes n distinct keys
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%v", i)
+	}
+	return keys
+}
+
+// ringWith builds a ring with the named nodes and every key inserted
+func ringWith(t *testing.T, nodes []string, keys []string) *Ring {
+	t.Helper()
+	r := NewRing(DefaultReplicas)
+	for _, name := range nodes {
+		if err := r.AddNode(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range keys {
+		if _, err := r.Insert(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func TestRingInsertSearchDelete(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []string
+		keys  int
+	}{
+		{"one node", []string{"a"}, 100},
+		{"three nodes", []string{"a", "b", "c"}, 1000},
+		{"no keys", []string{"a", "b"}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := testKeys(test.keys)
+			r := ringWith(t, test.nodes, keys)
+
+			total := 0
+			for _, count := range r.KeyCounts() {
+				total += count
+			}
+			if total != len(keys) {
+				t.Fatalf("the nodes hold %v keys, want %v", total, len(keys))
+			}
+			for _, key := range keys {
+				if !r.Search(key) {
+					t.Fatalf("%v is missing", key)
+				}
+				if !r.nodes[r.Node(key)].Search(key) {
+					t.Fatalf("%v isn't on %v, the node that owns it", key, r.Node(key))
+				}
+			}
+			for _, key := range keys {
+				if added, err := r.Insert(key); added || err != nil {
+					t.Fatalf("inserting %v again gave %v, %v", key, added, err)
+				}
+			}
+			for _, key := range keys[:len(keys)/2] {
+				if err := r.Delete(key); err != nil {
+					t.Fatalf("deleting %v: %v", key, err)
+				}
+			}
+			for i, key := range keys {
+				if want := i >= len(keys)/2; r.Search(key) != want {
+					t.Errorf("after deleting the first half, Search(%v) = %v", key, !want)
+				}
+			}
+			if err := r.Delete("never added"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleting a missing key gave %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestRingAddNodeMovesOnlyItsKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []string
+	}{
+		{"second node", []string{"a"}},
+		{"fourth node", []string{"a", "b", "c"}},
+		{"ninth node", []string{"a", "b", "c", "d", "e", "f", "g", "h"}},
+	}
+	const keyCount = 4000
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := testKeys(keyCount)
+			r := ringWith(t, test.nodes, keys)
+			before := make(map[string]string, len(keys))
+			for _, key := range keys {
+				before[key] = r.Node(key)
+			}
+
+			if err := r.AddNode("new"); err != nil {
+				t.Fatal(err)
+			}
+			moved := 0
+			for _, key := range keys {
+				owner := r.Node(key)
+				if owner != before[key] {
+					moved++
+					if owner != "new" {
+						t.Fatalf("%v moved from %v to %v, only the new node should take keys", key, before[key], owner)
+					}
+				}
+				if !r.nodes[owner].Search(key) {
+					t.Fatalf("%v isn't stored on %v after the move", key, owner)
+				}
+			}
+			if counts := r.KeyCounts(); counts["new"] != moved {
+				t.Errorf("the new node holds %v keys, %v moved to it", counts["new"], moved)
+			}
+			//the new node should take about its share of the keys, far from none and far from all of them
+			share := keyCount / (len(test.nodes) + 1)
+			if moved < share/3 || moved > share*3 {
+				t.Errorf("%v keys moved, want about %v", moved, share)
+			}
+		})
+	}
+}
+
+func TestRingRemoveNodeHandsOverItsKeys(t *testing.T) {
+	keys := testKeys(2000)
+	r := ringWith(t, []string{"a", "b", "c"}, keys)
+	before := make(map[string]string, len(keys))
+	for _, key := range keys {
+		before[key] = r.Node(key)
+	}
+
+	if err := r.RemoveNode("b"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if before[key] != "b" && r.Node(key) != before[key] {
+			t.Fatalf("%v moved from %v even though %v is still on the ring", key, before[key], before[key])
+		}
+		if !r.Search(key) {
+			t.Fatalf("%v was lost when b left", key)
+		}
+	}
+	if _, ok := r.KeyCounts()["b"]; ok {
+		t.Error("b still has a key count")
+	}
+}
+
+func TestRingErrors(t *testing.T) {
+	empty := NewRing(0)
+	if _, err := empty.Insert("x"); !errors.Is(err, ErrEmptyRing) {
+		t.Errorf("inserting into an empty ring gave %v, want ErrEmptyRing", err)
+	}
+
+	r := ringWith(t, []string{"a"}, testKeys(10))
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"add a node twice", r.AddNode("a")},
+		{"remove a node that isn't there", r.RemoveNode("z")},
+		{"remove the last node while it has keys", r.RemoveNode("a")},
+	}
+	for _, test := range tests {
+		if test.err == nil {
+			t.Errorf("%v: no error", test.name)
+		}
+	}
+	if !r.Search("key-0") {
+		t.Error("a failed remove lost keys")
+	}
+}